@@ -0,0 +1,297 @@
+// Command eeapi-verify checks a contract chain offline, from an exported
+// archive or straight from a read-only database connection, against the
+// node's public key. Chains read from the database are also checked against
+// the contract's signed head record.
+//
+// Exit codes: 0 when the chain is valid, 1 when it is not, 2 when it could
+// not be checked at all.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peiblow/eeapi/internal/archive"
+	"github.com/peiblow/eeapi/internal/blocks"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+const pageSize = 1000
+
+type Report struct {
+	Source          string    `json:"source"`
+	ContractID      string    `json:"contract_id"`
+	PublicKey       string    `json:"public_key"`
+	Blocks          int       `json:"blocks"`
+	HeadIndex       int64     `json:"head_index"`
+	HeadHash        string    `json:"head_hash"`
+	SignedHeadIndex int64     `json:"signed_head_index,omitempty"`
+	SignedHeadHash  string    `json:"signed_head_hash,omitempty"`
+	JournalsChecked int       `json:"journals_checked"`
+	Valid           bool      `json:"valid"`
+	Failures        []Failure `json:"failures"`
+}
+
+type Failure struct {
+	BlockIndex int64  `json:"block_index,omitempty"`
+	Kind       string `json:"kind"`
+	Expected   string `json:"expected,omitempty"`
+	Got        string `json:"got,omitempty"`
+	Error      string `json:"error"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("eeapi-verify", flag.ContinueOnError)
+	archivePath := fs.String("archive", "", "exported chain file, - for stdin")
+	dsn := fs.String("dsn", "", "database to read the chain from, read only")
+	contractID := fs.String("contract", "", "contract to verify when reading from -dsn")
+	pubHex := fs.String("pubkey", "", "hex encoded node public key")
+	pubFile := fs.String("pubkey-file", "", "file holding the node public key")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: eeapi-verify (-archive FILE | -dsn DSN -contract ID) (-pubkey HEX | -pubkey-file PATH) [-json]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 0 ||
+		(*archivePath == "") == (*dsn == "") ||
+		(*dsn != "" && *contractID == "") ||
+		(*pubHex == "") == (*pubFile == "") {
+		fs.Usage()
+		return 2
+	}
+
+	var pub ed25519.PublicKey
+	var err error
+	if *pubHex != "" {
+		pub, err = keys.ParsePublicKey(*pubHex)
+	} else {
+		pub, err = keys.LoadPublicKey(*pubFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load public key: %v\n", err)
+		return 2
+	}
+
+	report := &Report{PublicKey: fmt.Sprintf("0x%x", []byte(pub))}
+
+	var chain []*schema.Block
+	var journal blocks.JournalFunc
+	if *archivePath != "" {
+		a, err := readArchive(*archivePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read archive: %v\n", err)
+			return 2
+		}
+
+		report.Source = *archivePath
+		report.ContractID = a.Contract.ArtifactHash
+		chain = a.Blocks
+		journal = func(b *schema.Block) ([]byte, error) {
+			journalBytes, err := a.Journal(b)
+			if journalBytes != nil {
+				report.JournalsChecked++
+			}
+			return journalBytes, err
+		}
+	}
+
+	var head *schema.ContractHead
+	if *dsn != "" {
+		if chain, head, err = readChain(*dsn, *contractID); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read chain: %v\n", err)
+			return 2
+		}
+
+		report.Source = "database"
+		report.ContractID = *contractID
+	}
+
+	verify(report, chain, pub, journal)
+	if *dsn != "" {
+		verifyHead(report, chain, head, pub)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(os.Stdout, report)
+	}
+
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+func verify(report *Report, chain []*schema.Block, pub ed25519.PublicKey, journal blocks.JournalFunc) {
+	report.Blocks = len(chain)
+	if len(chain) == 0 {
+		report.Failures = append(report.Failures, Failure{Kind: "empty chain", Error: "no blocks to verify"})
+		return
+	}
+
+	head := chain[len(chain)-1]
+	report.HeadIndex = head.BlockIndex
+	report.HeadHash = head.Hash
+
+	for _, b := range chain {
+		if b.ContractID != report.ContractID {
+			report.Failures = append(report.Failures, Failure{
+				BlockIndex: b.BlockIndex,
+				Kind:       blocks.ErrContractMismatch.Error(),
+				Expected:   report.ContractID,
+				Got:        b.ContractID,
+				Error:      fmt.Sprintf("block #%d belongs to contract %s", b.BlockIndex, b.ContractID),
+			})
+		}
+	}
+
+	for _, err := range blocks.Audit(chain, pub, journal) {
+		failure := Failure{Kind: "error", Error: err.Error()}
+
+		var verr *blocks.VerificationError
+		if errors.As(err, &verr) {
+			failure.BlockIndex = verr.BlockIndex
+			failure.Kind = verr.Kind.Error()
+			failure.Expected = verr.Expected
+			failure.Got = verr.Got
+		}
+
+		report.Failures = append(report.Failures, failure)
+	}
+
+	report.Valid = len(report.Failures) == 0
+}
+
+// verifyHead checks the chain read from the database against the node's
+// signed head record, which catches blocks deleted from the end of the
+// chain. The head is read before the chain, so blocks appended in between
+// may follow it.
+func verifyHead(report *Report, chain []*schema.Block, head *schema.ContractHead, pub ed25519.PublicKey) {
+	defer func() { report.Valid = len(report.Failures) == 0 }()
+
+	if head == nil {
+		report.Failures = append(report.Failures, Failure{Kind: "missing head", Error: "contract has no signed head record"})
+		return
+	}
+	report.SignedHeadIndex = head.BlockIndex
+	report.SignedHeadHash = head.BlockHash
+
+	if !blocks.VerifyHead(head, pub) {
+		report.Failures = append(report.Failures, Failure{Kind: "head signature", Error: "signed head record has an invalid signature"})
+		return
+	}
+
+	for _, b := range chain {
+		if b.BlockIndex != head.BlockIndex {
+			continue
+		}
+		if b.Hash != head.BlockHash {
+			report.Failures = append(report.Failures, Failure{
+				BlockIndex: b.BlockIndex,
+				Kind:       "head mismatch",
+				Expected:   head.BlockHash,
+				Got:        b.Hash,
+				Error:      fmt.Sprintf("block #%d does not match the signed head", b.BlockIndex),
+			})
+		}
+		return
+	}
+
+	report.Failures = append(report.Failures, Failure{
+		BlockIndex: head.BlockIndex,
+		Kind:       "truncated",
+		Expected:   fmt.Sprintf("#%d %s", head.BlockIndex, head.BlockHash),
+		Got:        fmt.Sprintf("#%d %s", report.HeadIndex, report.HeadHash),
+		Error:      fmt.Sprintf("chain ends before the signed head #%d", head.BlockIndex),
+	})
+}
+
+func printReport(w io.Writer, report *Report) {
+	fmt.Fprintf(w, "Contract:   %s\n", report.ContractID)
+	fmt.Fprintf(w, "Source:     %s\n", report.Source)
+	fmt.Fprintf(w, "Public key: %s\n", report.PublicKey)
+	fmt.Fprintf(w, "Blocks:     %d\n", report.Blocks)
+	fmt.Fprintf(w, "Head:       #%d %s\n", report.HeadIndex, report.HeadHash)
+	if report.SignedHeadHash != "" {
+		fmt.Fprintf(w, "Signed:     #%d %s\n", report.SignedHeadIndex, report.SignedHeadHash)
+	}
+	fmt.Fprintf(w, "Journals:   %d checked\n", report.JournalsChecked)
+
+	if report.Valid {
+		fmt.Fprintln(w, "\nOK: every block links, hashes and is signed correctly")
+		return
+	}
+
+	fmt.Fprintf(w, "\nINVALID: %d problem(s) found\n", len(report.Failures))
+	for _, f := range report.Failures {
+		fmt.Fprintf(w, "  - %s\n", f.Error)
+	}
+}
+
+func readArchive(path string) (*archive.Archive, error) {
+	if path == "-" {
+		return archive.Read(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return archive.Read(f)
+}
+
+// readChain reads the contract's signed head record, then its chain.
+func readChain(dsn string, contractID string) ([]*schema.Block, *schema.ContractHead, error) {
+	db, err := postgres.OpenReadOnly(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	head, err := repository.NewPsqlHeadRepository(db).GetHead(ctx, contractID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo := repository.NewPsqlBlockRepository(db)
+
+	var chain []*schema.Block
+	var afterIndex int64
+	for {
+		page, err := repo.ListContractBlocks(ctx, contractID, afterIndex, pageSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain = append(chain, page...)
+
+		if len(page) < pageSize {
+			return chain, head, nil
+		}
+		afterIndex = page[len(page)-1].BlockIndex
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peiblow/eeapi/internal/archive"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+const defaultKeyFile = "keysStore/keys.pem"
+
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	contractID := fs.String("contract", "", "ID of the contract to export")
+	withJournals := fs.Bool("journals", false, "include decrypted journals")
+	out := fs.String("o", "-", "output file, - for stdout")
+	dsn := fs.String("dsn", postgres.DefaultDSN, "database connection string")
+	keyFile := fs.String("keys", defaultKeyFile, "node key file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: eeapi export -contract ID [-journals] [-o FILE]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *contractID == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	svc, closeDB, err := openArchiveService(*dsn, *keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	a, err := svc.Export(context.Background(), *contractID, *withJournals)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", *out, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := a.Write(w); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write archive: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d blocks of contract %s\n", len(a.Blocks), *contractID)
+	return 0
+}
+
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	pubHex := fs.String("pubkey", "", "hex encoded public key the chain must be signed with (default: this node's key)")
+	pubFile := fs.String("pubkey-file", "", "file holding the public key the chain must be signed with (default: this node's key)")
+	dsn := fs.String("dsn", postgres.DefaultDSN, "database connection string")
+	keyFile := fs.String("keys", defaultKeyFile, "node key file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: eeapi import [-pubkey HEX | -pubkey-file PATH] ARCHIVE")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 || (*pubHex != "" && *pubFile != "") {
+		fs.Usage()
+		return 2
+	}
+
+	var pub ed25519.PublicKey
+	var err error
+	switch {
+	case *pubHex != "":
+		pub, err = keys.ParsePublicKey(*pubHex)
+	case *pubFile != "":
+		pub, err = keys.LoadPublicKey(*pubFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load public key: %v\n", err)
+		return 2
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read archive: %v\n", err)
+		return 2
+	}
+
+	a, err := archive.Read(bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse archive: %v\n", err)
+		return 2
+	}
+
+	svc, closeDB, err := openArchiveService(*dsn, *keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	result, err := svc.Import(context.Background(), a, pub)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("imported %d blocks of contract %s, head #%d (%s)\n", result.Blocks, result.ContractID, result.HeadIndex, result.HeadHash)
+	return 0
+}
+
+func openArchiveService(dsn string, keyFile string) (service.ArchiveService, func(), error) {
+	pub, priv, err := keys.LoadOrCreateKeys(keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load keys: %w", err)
+	}
+
+	db, err := postgres.Open(dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return service.NewArchiveService(db, priv, pub), func() { db.Close() }, nil
+}
@@ -1,64 +1,191 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/peiblow/eeapi/internal/api"
 	"github.com/peiblow/eeapi/internal/auth"
 	"github.com/peiblow/eeapi/internal/config"
 	"github.com/peiblow/eeapi/internal/database/postgres"
 	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/outbox"
+	"github.com/peiblow/eeapi/internal/ratelimit"
+	"github.com/peiblow/eeapi/internal/retry"
 	"github.com/peiblow/eeapi/internal/swp"
+	"github.com/peiblow/eeapi/internal/tracing"
+	"github.com/peiblow/eeapi/internal/webhook"
 )
 
 func main() {
-	svm := swp.NewSwpClient("localhost:8332")
-	defer svm.Close()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify-receipt":
+			os.Exit(runVerifyReceipt(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		}
+	}
 
-	if err := svm.Connect(); err != nil {
-		slog.Error("Failed to connect to SVM server", "error", err)
-		os.Exit(1)
+	const keyPath = "keysStore/keys.pem"
+
+	cfg := config.Config{
+		Addr: ":8080",
+		DB:   config.DBConfig{DSN: postgres.DefaultDSN},
+
+		IdempotencyRetention: 24 * time.Hour,
+		JobWorkers:           4,
+		Webhook: webhook.Config{
+			MaxAttempts: 6,
+			BaseBackoff: time.Second,
+			MaxBackoff:  5 * time.Minute,
+			Timeout:     10 * time.Second,
+			Interval:    time.Second,
+			BatchSize:   50,
+
+			AllowPrivateTargets: os.Getenv("EEAPI_WEBHOOK_ALLOW_PRIVATE") == "true",
+		},
+		Outbox: outbox.Config{
+			Interval:  time.Second,
+			BatchSize: 100,
+			Lease:     time.Minute,
+		},
+		Checkpoint: config.CheckpointConfig{
+			Interval: time.Minute,
+		},
+		Watchdog: config.WatchdogConfig{
+			Interval: 5 * time.Minute,
+		},
+		Tracing: tracing.Config{
+			Exporter:    os.Getenv("OTEL_TRACES_EXPORTER"),
+			ServiceName: "eeapi",
+		},
+		Logging: logging.Config{
+			Format: os.Getenv("EEAPI_LOG_FORMAT"),
+			Level:  os.Getenv("EEAPI_LOG_LEVEL"),
+		},
+		RateLimit: ratelimit.Config{
+			Routes: map[string]ratelimit.Rule{
+				"execute": {
+					Identity: ratelimit.Limit{Rate: 20, Burst: 40},
+					Contract: ratelimit.Limit{Rate: 10, Burst: 20},
+				},
+				"deploy": {
+					Identity: ratelimit.Limit{Rate: 1, Burst: 5},
+				},
+				"import": {
+					Identity: ratelimit.Limit{Rate: 0.1, Burst: 2},
+				},
+			},
+		},
+		AdminSubjects:   adminSubjects(),
+		RequestTimeout:  60 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+		Reconnect: retry.Backoff{
+			Base: 500 * time.Millisecond,
+			Max:  30 * time.Second,
+		},
 	}
 
-	slog.Info("-> Connected to SVM server!")
+	if _, err := logging.Setup(cfg.Logging, os.Stdout); err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
+	}
 
-	db, err := postgres.Open()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		slog.Error("Failed to connect to database", "error", err)
+		slog.Error("Failed to set up tracing", "error", err)
 		os.Exit(1)
 	}
-	defer db.Close()
+	defer shutdownTracing(context.Background())
 
-	slog.Info("-> Connected to database!")
+	// Neither the SVM nor Postgres has to be up yet: the server connects to
+	// both in the background and reports not ready until it has.
+	svm := swp.NewSwpClient("localhost:8332")
 
-	cfg := config.Config{
-		Addr: ":8080",
-		DB:   config.DBConfig{},
+	db, err := postgres.New(cfg.DB.DSN)
+	if err != nil {
+		slog.Error("Invalid database configuration", "error", err)
+		os.Exit(1)
 	}
 
-	pub, priv, err := keys.LoadOrCreateKeys("keysStore/keys.pem")
+	pub, priv, err := keys.LoadOrCreateKeys(keyPath)
 	if err != nil {
 		slog.Error("Failed to load or create keys", "error", err)
 		os.Exit(1)
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	slog.SetDefault(logger)
-
 	locker := config.NewContractLocker()
 
-	server := api.NewServer(cfg, svm, db, pub, priv, locker)
+	server, err := api.NewServer(cfg, svm, db, pub, priv, locker)
+	if err != nil {
+		slog.Error("Failed to create server", "error", err)
+		os.Exit(1)
+	}
 
 	token, err := auth.GenerateJWT(priv)
 	if err != nil {
 		slog.Error("Failed to generate JWT token", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("Generated JWT token", "token", token)
 
-	if err := server.Run(); err != nil {
-		slog.Error("Server failed to start", "error", err)
+	// The token is a credential, so it goes to a private file rather than
+	// the logs.
+	tokenPath := filepath.Join(filepath.Dir(keyPath), "token.jwt")
+	if err := os.WriteFile(tokenPath, []byte(token+"\n"), 0o600); err != nil {
+		slog.Error("Failed to write JWT token", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Generated JWT token", "path", tokenPath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runErr := server.Run(ctx)
+	if runErr != nil && !errors.Is(runErr, http.ErrServerClosed) {
+		slog.Error("Server stopped with error", "error", runErr)
+	}
+
+	// The SVM goes first so nothing new reaches it, then the database once
+	// every writer has finished.
+	if err := svm.Close(); err != nil {
+		slog.Error("Failed to close SVM connection", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
+	slog.Info("Shutdown complete")
+
+	if runErr != nil && !errors.Is(runErr, http.ErrServerClosed) {
+		shutdownTracing(context.Background())
 		os.Exit(1)
 	}
 }
+
+// adminSubjects reads the comma-separated EEAPI_ADMIN_SUBJECTS, defaulting to
+// the subject of the token this binary generates.
+func adminSubjects() []string {
+	value := os.Getenv("EEAPI_ADMIN_SUBJECTS")
+	if value == "" {
+		return []string{"bff-service"}
+	}
+
+	var subjects []string
+	for _, subject := range strings.Split(value, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/receipt"
+)
+
+func runVerifyReceipt(args []string) int {
+	fs := flag.NewFlagSet("verify-receipt", flag.ContinueOnError)
+	pubHex := fs.String("pubkey", "", "hex encoded node public key")
+	pubFile := fs.String("pubkey-file", "", "file holding the node public key")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: eeapi verify-receipt (-pubkey HEX | -pubkey-file PATH) RECEIPT")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 || (*pubHex == "") == (*pubFile == "") {
+		fs.Usage()
+		return 2
+	}
+
+	var pub ed25519.PublicKey
+	var err error
+	if *pubHex != "" {
+		pub, err = keys.ParsePublicKey(*pubHex)
+	} else {
+		pub, err = keys.LoadPublicKey(*pubFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load public key: %v\n", err)
+		return 2
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read receipt: %v\n", err)
+		return 2
+	}
+
+	rcpt, err := receipt.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse receipt: %v\n", err)
+		return 2
+	}
+
+	if err := rcpt.Verify(pub); err != nil {
+		fmt.Printf("INVALID: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("OK: %s called on contract %s, recorded in block #%d (%s)\n", rcpt.Function, rcpt.ContractID, rcpt.BlockIndex, rcpt.BlockHash)
+	return 0
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/archive"
+	"github.com/peiblow/eeapi/internal/audit"
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+func ExportHandler(svc service.ArchiveService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contractID := chi.URLParam(r, "id")
+
+		withJournals := false
+		if v := r.URL.Query().Get("journals"); v != "" {
+			var err error
+			if withJournals, err = strconv.ParseBool(v); err != nil {
+				apperr.Write(w, r, apperr.New(apperr.CodeValidation, "journals must be a boolean"))
+				return
+			}
+		}
+
+		a, err := svc.Export(r.Context(), contractID, withJournals)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.chain.json"`, contractID))
+		a.Write(w)
+	}
+}
+
+func ImportHandler(svc service.ArchiveService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var pub ed25519.PublicKey
+		if v := r.URL.Query().Get("pubkey"); v != "" {
+			var err error
+			if pub, err = keys.ParsePublicKey(v); err != nil {
+				apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid pubkey", err))
+				return
+			}
+		}
+
+		a, err := archive.Read(r.Body)
+		if err != nil {
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid archive: "+err.Error(), err))
+			return
+		}
+
+		audit.SetContract(r.Context(), a.Contract.ArtifactHash)
+
+		result, err := svc.Import(r.Context(), a, pub)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+	}
+}
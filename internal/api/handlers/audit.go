@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/schema"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditEventsApiResponse struct {
+	Events []*schema.AuditEvent `json:"events"`
+	// NextAfter resumes the listing when passed as after; zero at the end.
+	NextAfter int64 `json:"next_after,omitempty"`
+}
+
+func AuditEventsHandler(svc service.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		filter := schema.AuditFilter{
+			Actor:      query.Get("actor"),
+			ContractID: query.Get("contract_id"),
+			Outcome:    query.Get("outcome"),
+			Limit:      defaultAuditLimit,
+		}
+
+		if v := query.Get("after"); v != "" {
+			after, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || after < 0 {
+				apperr.Write(w, r, apperr.New(apperr.CodeValidation, "after must be a sequence number"))
+				return
+			}
+			filter.AfterSeq = after
+		}
+
+		if v := query.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > maxAuditLimit {
+				apperr.Write(w, r, apperr.New(apperr.CodeValidation, "limit must be between 1 and 1000"))
+				return
+			}
+			filter.Limit = limit
+		}
+
+		if v := query.Get("from"); v != "" {
+			from, err := parseUsageTime(v, time.Time{})
+			if err != nil {
+				apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid from", err))
+				return
+			}
+			filter.From = from.UnixMilli()
+		}
+
+		if v := query.Get("to"); v != "" {
+			to, err := parseUsageTime(v, time.Time{})
+			if err != nil {
+				apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid to", err))
+				return
+			}
+			filter.To = to.UnixMilli()
+		}
+
+		events, err := svc.ListEvents(r.Context(), filter)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		resp := AuditEventsApiResponse{Events: events}
+		if resp.Events == nil {
+			resp.Events = []*schema.AuditEvent{}
+		}
+		if len(events) == filter.Limit {
+			resp.NextAfter = events[len(events)-1].Seq
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func AuditVerifyHandler(svc service.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := svc.Verify(r.Context())
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+func LatestCheckpointHandler(svc service.CheckpointService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cp, err := svc.Latest(r.Context())
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cp)
+	}
+}
+
+func CheckpointHandler(svc service.CheckpointService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		seq, err := strconv.ParseInt(chi.URLParam(r, "seq"), 10, 64)
+		if err != nil {
+			apperr.Write(w, r, apperr.New(apperr.CodeValidation, "checkpoint sequence must be an integer"))
+			return
+		}
+
+		cp, err := svc.Get(r.Context(), seq)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cp)
+	}
+}
+
+func BlockCheckpointHandler(svc service.CheckpointService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		index, err := strconv.ParseInt(chi.URLParam(r, "index"), 10, 64)
+		if err != nil || index < 1 {
+			apperr.Write(w, r, apperr.New(apperr.CodeValidation, "block index must be a positive integer"))
+			return
+		}
+
+		proof, err := svc.ProveBlock(r.Context(), chi.URLParam(r, "id"), index)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proof)
+	}
+}
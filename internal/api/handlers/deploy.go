@@ -3,65 +3,77 @@ package handlers
 import (
 	"encoding/json"
 	"io"
-	"log/slog"
 	"net/http"
+	"strconv"
 
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/audit"
 	"github.com/peiblow/eeapi/internal/service"
 	"github.com/peiblow/eeapi/internal/swp"
 )
 
 type DeployApiResponse struct {
+	ContractID      string `json:"contract_id"`
 	ContractHash    string `json:"contract_hash"`
 	ContractName    string `json:"contract_name"`
 	ContractOwner   string `json:"contract_owner"`
 	ContractVersion string `json:"contract_version"`
+	MaxPrice        int64  `json:"max_price,omitempty"`
 }
 
 func DeployHandler(svc service.ContractService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse multipart form (max 10MB)
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
-			http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
-			slog.Error("Failed to parse form", "error", err)
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid multipart form", err))
 			return
 		}
 
 		file, _, err := r.FormFile("source")
 		if err != nil {
-			http.Error(w, "Missing source file: "+err.Error(), http.StatusBadRequest)
-			slog.Error("Missing source file", "error", err)
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "missing source file", err))
 			return
 		}
 		defer file.Close()
 
 		source, err := io.ReadAll(file)
 		if err != nil {
-			http.Error(w, "Failed to read source file: "+err.Error(), http.StatusBadRequest)
-			slog.Error("Failed to read source file", "error", err)
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "failed to read source file", err))
 			return
 		}
 
+		var maxPrice int64
+		if v := r.FormValue("max_price"); v != "" {
+			if maxPrice, err = strconv.ParseInt(v, 10, 64); err != nil || maxPrice < 0 {
+				apperr.Write(w, r, apperr.New(apperr.CodeValidation, "max_price must be a non-negative integer"))
+				return
+			}
+		}
+
 		req := swp.DeployPayload{
 			Hash:         r.FormValue("hash"),
 			ContractName: r.FormValue("contract_name"),
 			Version:      r.FormValue("version"),
 			Owner:        r.FormValue("owner"),
 			Source:       source,
+			MaxPrice:     maxPrice,
 		}
 
-		contract, err := svc.DeployContract(r.Context(), &req)
+		result, err := svc.DeployContract(r.Context(), &req)
 		if err != nil {
-			http.Error(w, "Failed to deploy contract: "+err.Error(), http.StatusInternalServerError)
-			slog.Error("Failed to deploy contract", "error", err)
+			apperr.Write(w, r, err)
 			return
 		}
 
-		var resp DeployApiResponse
-		if err := json.Unmarshal(contract.Data, &resp); err != nil {
-			http.Error(w, "Failed to parse response: "+err.Error(), http.StatusInternalServerError)
-			slog.Error("Failed to parse response", "error", err)
-			return
+		resp := DeployApiResponse{
+			ContractID:      result.ContractID,
+			ContractHash:    result.Deploy.ContractHash,
+			ContractName:    result.Deploy.ContractName,
+			ContractOwner:   result.Deploy.ContractOwner,
+			ContractVersion: result.Deploy.ContractVersion,
+			MaxPrice:        maxPrice,
 		}
+		audit.SetContract(r.Context(), result.ContractID)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -1,47 +1,105 @@
 package handlers
 
 import (
+	"encoding/hex"
 	"encoding/json"
-	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/audit"
 	"github.com/peiblow/eeapi/internal/service"
 	"github.com/peiblow/eeapi/internal/swp"
 )
 
 type ExecApiResponse struct {
-	id       string                   `json:"id"`
-	Price    int                      `json:"price"`
-	Function string                   `json:"function"`
-	Journal  []map[string]interface{} `json:"journal"`
+	ID           string        `json:"id"`
+	ContractID   string        `json:"contract_id"`
+	Function     string        `json:"function"`
+	Journal      []interface{} `json:"journal"`
+	ExecPrice    int64         `json:"exec_price"`
+	BlockIndex   int64         `json:"block_index"`
+	BlockHash    string        `json:"block_hash"`
+	PreviousHash string        `json:"previous_hash"`
+	JournalHash  string        `json:"journal_hash"`
+	JournalRoot  string        `json:"journal_root"`
+	Signature    string        `json:"signature"`
+	Timestamp    int64         `json:"timestamp"`
+	HashVersion  int           `json:"hash_version"`
 }
 
-func ExecHandler(svc service.ContractService) http.HandlerFunc {
+func NewExecApiResponse(result *service.ExecutionResult) ExecApiResponse {
+	return ExecApiResponse{
+		ID:           result.RequestID,
+		ContractID:   result.Block.ContractID,
+		Function:     result.Block.FunctionName,
+		Journal:      result.Exec.Journal,
+		ExecPrice:    result.Exec.ExecPrice,
+		BlockIndex:   result.Block.BlockIndex,
+		BlockHash:    result.Block.Hash,
+		PreviousHash: result.Block.PreviousHash,
+		JournalHash:  result.Block.JournalHash,
+		JournalRoot:  result.Block.JournalRoot,
+		Signature:    "0x" + hex.EncodeToString(result.Block.Signature),
+		Timestamp:    result.Block.Timestamp,
+		HashVersion:  result.Block.HashVersion,
+	}
+}
+
+type AsyncExecApiResponse struct {
+	JobID     string `json:"job_id"`
+	Status    string `json:"status"`
+	StatusURL string `json:"status_url"`
+}
+
+func ExecHandler(svc service.ContractService, jobs service.JobService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 
 		var req swp.ExecPayload
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid request payload", err))
 			return
 		}
 
-		result, err := svc.ExecuteContract(r.Context(), id, &req)
-		if err != nil {
-			http.Error(w, "Failed to execute contract: "+err.Error(), http.StatusInternalServerError)
-			slog.Error("Failed to execute contract", "error", err)
+		if req.Function == "" {
+			apperr.Write(w, r, apperr.New(apperr.CodeValidation, "function is required"))
 			return
 		}
 
-		var resp ExecApiResponse
-		if err := json.Unmarshal(result.Data, &resp); err != nil {
-			http.Error(w, "Failed to parse response: "+err.Error(), http.StatusInternalServerError)
-			slog.Error("Failed to parse response", "error", err)
+		audit.SetFunction(r.Context(), req.Function)
+
+		if req.MaxPrice < 0 {
+			apperr.Write(w, r, apperr.New(apperr.CodeValidation, "max_price must not be negative"))
+			return
+		}
+
+		if r.URL.Query().Get("async") == "true" {
+			job, err := jobs.Enqueue(r.Context(), id, &req)
+			if err != nil {
+				apperr.Write(w, r, err)
+				return
+			}
+
+			statusURL := "/jobs/" + job.ID
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", statusURL)
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(AsyncExecApiResponse{
+				JobID:     job.ID,
+				Status:    string(job.Status),
+				StatusURL: statusURL,
+			})
+			return
+		}
+
+		result, err := svc.ExecuteContract(r.Context(), id, &req)
+		if err != nil {
+			apperr.Write(w, r, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
+		json.NewEncoder(w).Encode(NewExecApiResponse(result))
 	}
 }
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+type JobError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type JobApiResponse struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"`
+	ContractID string           `json:"contract_id"`
+	Function   string           `json:"function"`
+	BlockIndex int64            `json:"block_index,omitempty"`
+	BlockHash  string           `json:"block_hash,omitempty"`
+	Result     *ExecApiResponse `json:"result,omitempty"`
+	Error      *JobError        `json:"error,omitempty"`
+	CreatedAt  int64            `json:"created_at"`
+	UpdatedAt  int64            `json:"updated_at"`
+}
+
+func JobHandler(jobs service.JobService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := jobs.GetJob(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		resp := JobApiResponse{
+			ID:         job.ID,
+			Status:     string(job.Status),
+			ContractID: job.ContractID,
+			Function:   job.FunctionName,
+			BlockIndex: job.BlockIndex,
+			BlockHash:  job.BlockHash,
+			CreatedAt:  job.CreatedAt,
+			UpdatedAt:  job.UpdatedAt,
+		}
+
+		if job.ErrorCode != "" {
+			resp.Error = &JobError{Code: job.ErrorCode, Message: job.Error}
+		}
+
+		result, err := jobs.JobResult(job)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+		if result != nil {
+			execResp := NewExecApiResponse(result)
+			resp.Result = &execResp
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+func JournalProofHandler(svc service.ContractService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		index, err := strconv.ParseInt(chi.URLParam(r, "index"), 10, 64)
+		if err != nil {
+			apperr.Write(w, r, apperr.New(apperr.CodeValidation, "block index must be an integer"))
+			return
+		}
+
+		entry, err := strconv.Atoi(r.URL.Query().Get("entry"))
+		if err != nil {
+			apperr.Write(w, r, apperr.New(apperr.CodeValidation, "entry must be an integer"))
+			return
+		}
+
+		proof, err := svc.GetJournalProof(r.Context(), chi.URLParam(r, "id"), index, entry)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proof)
+	}
+}
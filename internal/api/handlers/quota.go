@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/auth"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+// QuotaHandler reports the caller's execution quota and ExecPrice spend.
+func QuotaHandler(quotas service.QuotaService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usage, err := quotas.Usage(r.Context(), auth.Identity(r.Context()))
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(usage)
+	}
+}
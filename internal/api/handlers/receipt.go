@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+func ReceiptHandler(svc service.ContractService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		blockHash := chi.URLParam(r, "blockHash")
+
+		rcpt, err := svc.GetReceipt(r.Context(), blockHash)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "application/cbor") {
+			data, err := rcpt.MarshalCBOR()
+			if err != nil {
+				apperr.Write(w, r, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/cbor")
+			w.Write(data)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rcpt)
+	}
+}
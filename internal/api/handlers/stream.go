@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/service"
+	"github.com/peiblow/eeapi/internal/stream"
+)
+
+const (
+	replayPageSize    = 100
+	heartbeatInterval = 15 * time.Second
+)
+
+func BlockStreamHandler(svc service.ContractService, b *stream.Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contractID := chi.URLParam(r, "id")
+
+		lastIndex, err := lastEventID(r)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		// Streams outlive the server's write timeout.
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+
+		send := func(event events.Event) error {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", stream.BlockIndex(event), event.Type, data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		heartbeat := func() error {
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		err = stream.Follow(r.Context(), b, contractID, lastIndex, replayBlocks(svc), send, heartbeat, heartbeatInterval)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logging.FromContext(r.Context()).Info("Block stream closed", "contract_id", contractID, "error", err)
+		}
+	}
+}
+
+func BlockWebSocketHandler(svc service.ContractService, b *stream.Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contractID := chi.URLParam(r, "id")
+
+		lastIndex, err := lastEventID(r)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to accept websocket", "contract_id", contractID, "error", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		// The client only listens; reading in the background handles control
+		// frames and cancels ctx once the client goes away.
+		ctx := conn.CloseRead(r.Context())
+
+		send := func(event events.Event) error {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			return conn.Write(ctx, websocket.MessageText, data)
+		}
+		heartbeat := func() error {
+			return conn.Ping(ctx)
+		}
+
+		err = stream.Follow(ctx, b, contractID, lastIndex, replayBlocks(svc), send, heartbeat, heartbeatInterval)
+		if errors.Is(err, stream.ErrSubscriberLagged) {
+			conn.Close(websocket.StatusTryAgainLater, "subscriber lagged, resume from last event id")
+			return
+		}
+		if errors.Is(err, stream.ErrClosed) {
+			conn.Close(websocket.StatusGoingAway, "server shutting down")
+			return
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+	}
+}
+
+// lastEventID reads the resume point from the Last-Event-ID header, or the
+// last_event_id query parameter for clients that cannot set headers.
+func lastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	index, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || index < 0 {
+		return 0, apperr.New(apperr.CodeValidation, "Last-Event-ID must be a block index")
+	}
+
+	return index, nil
+}
+
+func replayBlocks(svc service.ContractService) stream.ReplayFunc {
+	return func(ctx context.Context, contractID string, afterIndex int64) ([]events.Event, error) {
+		for {
+			blocks, err := svc.ListBlocks(ctx, contractID, afterIndex, replayPageSize)
+			if err != nil {
+				return nil, err
+			}
+
+			page := make([]events.Event, 0, len(blocks))
+			for _, block := range blocks {
+				if block.FunctionName == "genesis" {
+					continue
+				}
+
+				event, err := events.NewBlockEvent(block)
+				if err != nil {
+					return nil, err
+				}
+				page = append(page, event)
+			}
+
+			// A page holding only the genesis block must not end the replay.
+			if len(page) > 0 || len(blocks) == 0 {
+				return page, nil
+			}
+			afterIndex = blocks[len(blocks)-1].BlockIndex
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/schema"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+const defaultUsageWindow = 30 * 24 * time.Hour
+
+// UsageHandler reports VM usage from the billing ledger. from and to accept
+// unix milliseconds, RFC 3339 timestamps or dates and default to the last 30
+// days. The report is JSON unless format=csv or the client accepts text/csv.
+func UsageHandler(svc service.UsageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		now := time.Now().UTC()
+		to, err := parseUsageTime(query.Get("to"), now)
+		if err != nil {
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid to", err))
+			return
+		}
+		from, err := parseUsageTime(query.Get("from"), to.Add(-defaultUsageWindow))
+		if err != nil {
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid from", err))
+			return
+		}
+
+		groupBy := schema.UsageGroup(query.Get("group_by"))
+		if groupBy == "" {
+			groupBy = schema.UsageByContract
+		}
+
+		report, err := svc.Usage(r.Context(), from.UnixMilli(), to.UnixMilli(), groupBy)
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		if query.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			writeUsageCSV(w, report)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+func parseUsageTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a unix millisecond timestamp, RFC 3339 time or date", value)
+}
+
+func writeUsageCSV(w http.ResponseWriter, report *service.UsageReport) {
+	var header []string
+	var fields func(row *schema.UsageRow) []string
+
+	switch report.GroupBy {
+	case schema.UsageByContract:
+		header = []string{"contract_id", "owner"}
+		fields = func(row *schema.UsageRow) []string { return []string{row.ContractID, row.Owner} }
+	case schema.UsageByFunction:
+		header = []string{"contract_id", "function"}
+		fields = func(row *schema.UsageRow) []string { return []string{row.ContractID, row.Function} }
+	case schema.UsageByCaller:
+		header = []string{"caller"}
+		fields = func(row *schema.UsageRow) []string { return []string{row.Caller} }
+	case schema.UsageByOwner:
+		header = []string{"owner"}
+		fields = func(row *schema.UsageRow) []string { return []string{row.Owner} }
+	}
+
+	filename := fmt.Sprintf("usage-%s-%s-%s.csv",
+		report.GroupBy,
+		time.UnixMilli(report.From).UTC().Format(time.DateOnly),
+		time.UnixMilli(report.To).UTC().Format(time.DateOnly),
+	)
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	cw := csv.NewWriter(w)
+	cw.Write(append(header, "executions", "exec_price"))
+	for _, row := range report.Rows {
+		cw.Write(append(fields(row),
+			strconv.FormatInt(row.Executions, 10),
+			strconv.FormatInt(row.ExecPrice, 10),
+		))
+	}
+	cw.Flush()
+}
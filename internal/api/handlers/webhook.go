@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/schema"
+	"github.com/peiblow/eeapi/internal/service"
+)
+
+type WebhookPayload struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	ContractID string   `json:"contract_id"`
+	Function   string   `json:"function"`
+	EventTypes []string `json:"event_types"`
+}
+
+type WebhookTestApiResponse struct {
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func RegisterWebhookHandler(svc service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "invalid request payload", err))
+			return
+		}
+
+		sub, err := svc.Register(r.Context(), &schema.WebhookSubscription{
+			URL:        req.URL,
+			Secret:     req.Secret,
+			ContractID: req.ContractID,
+			Function:   req.Function,
+			EventTypes: req.EventTypes,
+		})
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sub)
+	}
+}
+
+func ListWebhooksHandler(svc service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := svc.List(r.Context())
+		if err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		if subs == nil {
+			subs = []*schema.WebhookSubscription{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(subs)
+	}
+}
+
+func TestWebhookHandler(svc service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := svc.Test(r.Context(), chi.URLParam(r, "id"))
+
+		var appErr *apperr.Error
+		if err != nil && status == 0 && errors.As(err, &appErr) {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		resp := WebhookTestApiResponse{Delivered: err == nil, StatusCode: status}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func DeleteWebhookHandler(svc service.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -2,28 +2,68 @@ package api
 
 import (
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/peiblow/eeapi/internal/api/handlers"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/audit"
 	"github.com/peiblow/eeapi/internal/auth"
-	"github.com/peiblow/eeapi/internal/service"
+	"github.com/peiblow/eeapi/internal/idempotency"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/metrics"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/tracing"
 )
 
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// allowedMethods lists the methods routes serves path with, for the Allow
+// header of a 405.
+func allowedMethods(routes chi.Routes, path string) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		if routes.Match(chi.NewRouteContext(), method, path) {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
 func (s *Server) mount() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+	r.Use(audit.Middleware(repository.NewPsqlAuditRepository(s.db)))
 	r.Use(middleware.Recoverer)
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apperr.Write(w, r, apperr.New(apperr.CodeNotFound, "route not found"))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(r, req.URL.Path), ", "))
+		apperr.Write(w, req, apperr.New(apperr.CodeMethodNotAllowed, "method not allowed"))
+	})
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
+	r.Get("/healthz", handlers.HealthzHandler())
+	r.Get("/readyz", handlers.ReadyzHandler(s.health))
+	r.Handle("/metrics", metrics.Handler())
 
 	// authHandler := handlers.NewAuthHandler(service.NewUserService(s.db))
 
@@ -35,9 +75,43 @@ func (s *Server) mount() http.Handler {
 	r.Route("/", func(r chi.Router) {
 		r.Use(auth.JWTMiddleware(s.pub))
 
-		contractSvc := service.NewContractService(s.svm, s.db, s.priv, s.pub, s.locker)
-		r.Post("/contracts/deploy", handlers.DeployHandler(contractSvc))
-		r.Post("/contracts/{id}/execute", handlers.ExecHandler(contractSvc))
+		// Long-lived streams are not subject to the request timeout.
+		r.Get("/contracts/{id}/blocks/stream", handlers.BlockStreamHandler(s.contracts, s.broadcaster))
+		r.Get("/contracts/{id}/blocks/ws", handlers.BlockWebSocketHandler(s.contracts, s.broadcaster))
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(s.cfg.RequestTimeout))
+
+			idempotent := idempotency.Middleware(repository.NewPsqlIdempotencyRepository(s.db), s.cfg.IdempotencyRetention)
+
+			limit := s.limiter.Middleware
+
+			r.With(limit("deploy"), idempotent).Post("/contracts/deploy", handlers.DeployHandler(s.contracts))
+			r.With(limit("execute"), idempotent).Post("/contracts/{id}/execute", handlers.ExecHandler(s.contracts, s.jobs))
+			r.With(limit("export")).Get("/contracts/{id}/export", handlers.ExportHandler(s.archives))
+			r.Get("/contracts/{id}/blocks/{index}/proof", handlers.JournalProofHandler(s.contracts))
+			r.Get("/contracts/{id}/blocks/{index}/checkpoint", handlers.BlockCheckpointHandler(s.checkpoints))
+			r.Get("/receipts/{blockHash}", handlers.ReceiptHandler(s.contracts))
+			r.Get("/checkpoints/latest", handlers.LatestCheckpointHandler(s.checkpoints))
+			r.Get("/checkpoints/{seq}", handlers.CheckpointHandler(s.checkpoints))
+			r.Get("/jobs/{id}", handlers.JobHandler(s.jobs))
+			r.Get("/quota", handlers.QuotaHandler(s.quotas))
+			r.Get("/usage", handlers.UsageHandler(s.usage))
+			r.Get("/debug/status", handlers.DebugStatusHandler(s.health))
+
+			r.Post("/webhooks", handlers.RegisterWebhookHandler(s.webhooks))
+			r.Get("/webhooks", handlers.ListWebhooksHandler(s.webhooks))
+			r.Post("/webhooks/{id}/test", handlers.TestWebhookHandler(s.webhooks))
+			r.Delete("/webhooks/{id}", handlers.DeleteWebhookHandler(s.webhooks))
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireSubject(s.cfg.AdminSubjects))
+
+				r.With(limit("import")).Post("/contracts/import", handlers.ImportHandler(s.archives))
+				r.Get("/audit/events", handlers.AuditEventsHandler(s.audit))
+				r.Get("/audit/verify", handlers.AuditVerifyHandler(s.audit))
+			})
+		})
 	})
 
 	return r
@@ -1,16 +1,36 @@
 package api
 
 import (
+	"context"
 	"crypto/ed25519"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/peiblow/eeapi/internal/checkpoint"
 	"github.com/peiblow/eeapi/internal/config"
 	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/idempotency"
+	"github.com/peiblow/eeapi/internal/outbox"
+	"github.com/peiblow/eeapi/internal/ratelimit"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/retry"
+	"github.com/peiblow/eeapi/internal/service"
+	"github.com/peiblow/eeapi/internal/stream"
 	"github.com/peiblow/eeapi/internal/swp"
+	"github.com/peiblow/eeapi/internal/webhook"
 )
 
+// writeTimeoutMargin is how much longer than the request timeout the server
+// waits for a response to be written, so the timeout's own error response
+// still reaches the client.
+const writeTimeoutMargin = 10 * time.Second
+
 type Server struct {
 	cfg  config.Config
 	svm  *swp.SwpClient
@@ -19,26 +39,228 @@ type Server struct {
 	priv ed25519.PrivateKey
 
 	locker *config.ContractLocker
+
+	contracts   service.ContractService
+	jobs        service.JobService
+	webhooks    service.WebhookService
+	checkpoints service.CheckpointService
+	archives    service.ArchiveService
+	watchdog    service.WatchdogService
+	health      service.HealthService
+	quotas      service.QuotaService
+	usage       service.UsageService
+	audit       service.AuditService
+
+	dispatcher  *webhook.Dispatcher
+	relay       *outbox.Relay
+	broadcaster *stream.Broadcaster
+	limiter     *ratelimit.Limiter
+	origin      string
 }
 
-func NewServer(cfg config.Config, svm *swp.SwpClient, db *postgres.DB, pub []byte, priv []byte, locker *config.ContractLocker) *Server {
+func NewServer(cfg config.Config, svm *swp.SwpClient, db *postgres.DB, pub []byte, priv []byte, locker *config.ContractLocker) (*Server, error) {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 60 * time.Second
+	}
+
+	dispatcher := webhook.NewDispatcher(repository.NewPsqlWebhookRepository(db), cfg.Webhook)
+	broadcaster := stream.NewBroadcaster()
+	origin := uuid.New().String()
+
+	sinks, err := outboxSinks(cfg.Outbox, dispatcher)
+	if err != nil {
+		return nil, err
+	}
+	relay := outbox.NewRelay(repository.NewPsqlOutboxRepository(db), cfg.Outbox, sinks...)
+
+	live := events.Publishers{broadcaster}
+	if cfg.NotifyReplicas {
+		live = append(live, stream.NewPgNotifier(db, origin))
+	}
+	quotas := service.NewQuotaService(db, cfg.Quota)
+	contracts := service.NewContractService(svm, db, priv, pub, locker, live, quotas)
+
 	return &Server{
-		cfg,
-		svm,
-		db,
-		pub,
-		priv,
-		locker,
+		cfg:         cfg,
+		svm:         svm,
+		db:          db,
+		pub:         pub,
+		priv:        priv,
+		locker:      locker,
+		contracts:   contracts,
+		quotas:      quotas,
+		usage:       service.NewUsageService(db),
+		audit:       service.NewAuditService(db),
+		jobs:        service.NewJobService(contracts, db, priv, cfg.JobWorkers),
+		webhooks:    service.NewWebhookService(db, dispatcher),
+		checkpoints: service.NewCheckpointService(db, priv, checkpointExporters(cfg.Checkpoint)...),
+		archives:    service.NewArchiveService(db, priv, pub),
+		watchdog:    service.NewWatchdogService(db, priv, pub, locker),
+		health:      service.NewHealthService(db, svm, locker, pub),
+		dispatcher:  dispatcher,
+		relay:       relay,
+		broadcaster: broadcaster,
+		limiter:     ratelimit.New(cfg.RateLimit),
+		origin:      origin,
+	}, nil
+}
+
+func checkpointExporters(cfg config.CheckpointConfig) []checkpoint.Exporter {
+	var exporters []checkpoint.Exporter
+
+	if cfg.File != "" {
+		exporters = append(exporters, checkpoint.NewFileExporter(cfg.File))
 	}
+
+	if cfg.URL != "" {
+		exporters = append(exporters, checkpoint.NewHTTPExporter(cfg.URL))
+	}
+
+	return exporters
 }
 
-func (s *Server) Run() error {
+func outboxSinks(cfg outbox.Config, dispatcher *webhook.Dispatcher) ([]events.Publisher, error) {
+	sinks := []events.Publisher{dispatcher}
+
+	if cfg.LogFile != "" {
+		sink, err := outbox.NewLogFileSink(cfg.LogFile)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if cfg.Topic != "" {
+		sinks = append(sinks, outbox.NewBrokerSink(outbox.NewMemoryBroker(), cfg.Topic))
+	}
+
+	return sinks, nil
+}
+
+// Run serves until ctx is cancelled, then shuts down in order: stop taking
+// requests and wait for in-flight ones (so executions finish writing their
+// blocks), end live streams, drain the job workers and stop the background
+// loops, letting webhook deliveries in flight finish. Queued deliveries are
+// sent after the next start.
+//
+// The server listens straight away; readiness stays false until Postgres is
+// reachable and the workers are running, and the SVM is dialled in the
+// background for as long as the server runs.
+func (s *Server) Run(ctx context.Context) error {
+	// Background loops outlive ctx so they can be stopped after the HTTP
+	// server has drained.
+	loopCtx, stopLoops := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopLoops()
+
+	var loops sync.WaitGroup
+	run := func(fn func()) {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			fn()
+		}()
+	}
+
+	run(func() { s.svm.KeepConnected(loopCtx, s.cfg.Reconnect) })
+
 	srv := &http.Server{
 		Addr:         s.cfg.Addr,
 		Handler:      s.mount(),
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: s.cfg.RequestTimeout + writeTimeoutMargin,
+	}
+	srv.RegisterOnShutdown(s.broadcaster.Close)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server started", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	startCtx, cancelStart := context.WithCancel(ctx)
+	defer cancelStart()
+
+	startDone := make(chan struct{})
+	go func() {
+		defer close(startDone)
+		s.start(startCtx, loopCtx, run)
+	}()
+
+	var runErr error
+	select {
+	case runErr = <-serveErr:
+	case <-ctx.Done():
+	}
+
+	cancelStart()
+	<-startDone
+
+	timeout := s.cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	slog.Info("Shutting down", "timeout", timeout)
+	errs := []error{runErr}
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server did not drain", "error", err)
+		errs = append(errs, err)
+	}
+	slog.Info("HTTP server drained")
+
+	if err := s.jobs.Stop(shutdownCtx); err != nil {
+		slog.Error("Job workers did not drain", "error", err)
+		errs = append(errs, err)
+	}
+	slog.Info("Job workers stopped")
+
+	stopLoops()
+	loops.Wait()
+	slog.Info("Background loops stopped")
+
+	return errors.Join(errs...)
+}
+
+// start waits for Postgres, then launches the job workers and the loops that
+// need the database. It gives up only when ctx is cancelled.
+func (s *Server) start(ctx, loopCtx context.Context, run func(func())) {
+	err := retry.Do(ctx, s.cfg.Reconnect, func(ctx context.Context) error {
+		if err := s.db.PingContext(ctx); err != nil {
+			return err
+		}
+		return s.jobs.Start(loopCtx)
+	}, func(attempt int, err error, delay time.Duration) {
+		slog.Warn("Database unavailable, retrying", "attempt", attempt, "retry_in", delay, "error", err)
+	})
+	if err != nil {
+		return
+	}
+	slog.Info("Connected to database")
+
+	run(func() { s.relay.Run(loopCtx) })
+	run(func() { s.dispatcher.Run(loopCtx) })
+	run(func() {
+		idempotency.Sweep(loopCtx, repository.NewPsqlIdempotencyRepository(s.db), s.cfg.IdempotencyRetention)
+	})
+
+	if s.cfg.Checkpoint.Interval > 0 {
+		run(func() { s.checkpoints.Run(loopCtx, s.cfg.Checkpoint.Interval) })
+	}
+
+	if s.cfg.Watchdog.Interval > 0 {
+		run(func() { s.watchdog.Run(loopCtx, s.cfg.Watchdog.Interval) })
+	}
+
+	if s.cfg.NotifyReplicas {
+		run(func() {
+			err := stream.Listen(loopCtx, s.cfg.DB.DSN, s.origin, s.broadcaster)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Block notification listener stopped", "error", err)
+			}
+		})
 	}
 
-	log.Printf("Server started at %s", srv.Addr)
-	return srv.ListenAndServe()
+	s.health.MarkStarted()
 }
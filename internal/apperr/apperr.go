@@ -0,0 +1,103 @@
+package apperr
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+type Code string
+
+const (
+	CodeValidation       Code = "VALIDATION"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodeVMRejected       Code = "VM_REJECTED"
+	CodeVMUnavailable    Code = "VM_UNAVAILABLE"
+	CodeConflict         Code = "CONFLICT"
+	CodeFrozen           Code = "CONTRACT_FROZEN"
+	CodeRateLimited      Code = "RATE_LIMITED"
+	CodeQuotaExceeded    Code = "QUOTA_EXCEEDED"
+	CodePriceExceeded    Code = "PRICE_LIMIT_EXCEEDED"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeInternal         Code = "INTERNAL"
+)
+
+// Status returns the HTTP status code a given error code is reported with.
+func (c Code) Status() int {
+	switch c {
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeVMRejected, CodePriceExceeded:
+		return http.StatusUnprocessableEntity
+	case CodeVMUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeFrozen:
+		return http.StatusLocked
+	case CodeRateLimited, CodeQuotaExceeded:
+		return http.StatusTooManyRequests
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is an error that is safe to report to API clients. Message is
+// returned to the client, Err is only ever logged.
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+	// Details are machine-readable facts about the error, returned to the
+	// client alongside Message.
+	Details map[string]any
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func (e *Error) WithDetails(details map[string]any) *Error {
+	e.Details = details
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// From converts any error into an *Error. Errors that are not already typed
+// are mapped by their cause, falling back to an opaque internal error.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Wrap(CodeNotFound, "resource not found", err)
+	}
+
+	return Wrap(CodeInternal, "internal server error", err)
+}
@@ -0,0 +1,50 @@
+package apperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/peiblow/eeapi/internal/logging"
+)
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      Code           `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	status := e.Code.Status()
+	requestID := middleware.GetReqID(r.Context())
+
+	logger := logging.FromContext(r.Context())
+	if status >= http.StatusInternalServerError {
+		logger.Error("Request failed", "code", e.Code, "path", r.URL.Path, "error", err)
+	} else {
+		logger.Warn("Request rejected", "code", e.Code, "path", r.URL.Path, "error", err)
+	}
+
+	problem := Problem{
+		Type:      "urn:eeapi:error:" + strings.ToLower(string(e.Code)),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: requestID,
+		Details:   e.Details,
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
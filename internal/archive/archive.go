@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/peiblow/eeapi/internal/blocks"
+	"github.com/peiblow/eeapi/internal/schema"
+	"github.com/peiblow/eeapi/internal/swp"
+)
+
+const (
+	Format  = "eeapi-chain"
+	Version = 1
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	ErrNoPublicKey       = errors.New("no trusted public key to verify the archive with")
+	ErrEmptyChain        = errors.New("archive has no blocks")
+)
+
+// Archive is a self-describing export of one contract: its artifact, the
+// agent that compiled it, its whole block chain and the node keys that
+// signed it. Journals are optional and, when present, decrypted and keyed
+// by block index.
+type Archive struct {
+	Format     string                    `json:"format"`
+	Version    int                       `json:"version"`
+	ExportedAt int64                     `json:"exported_at"`
+	Contract   schema.Contract           `json:"contract"`
+	Agent      swp.AgentMeta             `json:"agent"`
+	Artifact   swp.ArtifactMetadata      `json:"artifact"`
+	PublicKeys []string                  `json:"public_keys"`
+	Blocks     []*schema.Block           `json:"blocks"`
+	Journals   map[int64]json.RawMessage `json:"journals,omitempty"`
+}
+
+func Read(r io.Reader) (*Archive, error) {
+	var a Archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, err
+	}
+
+	if a.Format != Format || a.Version != Version {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnsupportedFormat, a.Format, a.Version)
+	}
+
+	return &a, nil
+}
+
+func (a *Archive) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// Journal returns the journal bytes a block committed to, re-encoded from
+// the archived journal for the block's hash version, or nil when the archive
+// carries no journal for it.
+func (a *Archive) Journal(b *schema.Block) ([]byte, error) {
+	raw, ok := a.Journals[b.BlockIndex]
+	if !ok {
+		return nil, nil
+	}
+
+	var journal []any
+	if err := json.Unmarshal(raw, &journal); err != nil {
+		return nil, err
+	}
+
+	return blocks.EncodeJournal(blocks.Version(b), journal)
+}
+
+// Verify checks the whole chain, including the archived journals, against
+// pub. The keys listed in the archive are informational only: whoever built
+// the archive chose them, so they are never trusted.
+func (a *Archive) Verify(pub ed25519.PublicKey) error {
+	if pub == nil {
+		return ErrNoPublicKey
+	}
+
+	if len(a.Blocks) == 0 {
+		return ErrEmptyChain
+	}
+
+	for _, b := range a.Blocks {
+		if b.ContractID != a.Contract.ArtifactHash {
+			return fmt.Errorf("block #%d belongs to contract %s, not %s", b.BlockIndex, b.ContractID, a.Contract.ArtifactHash)
+		}
+	}
+
+	return blocks.VerifyChain(a.Blocks, pub, a.Journal)
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// GenesisHash is the previous hash of the first audit event.
+const GenesisHash = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
+const (
+	OutcomeSuccess      = "success"
+	OutcomeRejected     = "rejected"
+	OutcomeUnauthorized = "unauthorized"
+	OutcomeFailed       = "failed"
+)
+
+var ErrBrokenChain = errors.New("audit chain broken")
+
+// Hash computes the chained hash of an event: a domain tag followed by its
+// fields, each string length-prefixed, in the same style as block hashes.
+func Hash(e *schema.AuditEvent) string {
+	var buf bytes.Buffer
+	buf.WriteString("EEAPI-AUDIT")
+	writeString(&buf, e.PreviousHash)
+	writeInt(&buf, e.Seq)
+	writeString(&buf, e.RequestID)
+	writeString(&buf, e.Actor)
+	writeString(&buf, e.Method)
+	writeString(&buf, e.Route)
+	writeString(&buf, e.Path)
+	writeString(&buf, e.ContractID)
+	writeString(&buf, e.Function)
+	writeInt(&buf, int64(e.Status))
+	writeString(&buf, e.Outcome)
+	writeString(&buf, e.RemoteAddr)
+	writeInt(&buf, e.CreatedAt)
+
+	sum := sha256.Sum256(buf.Bytes())
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+// Verify checks that events continue the chain from the event with sequence
+// number prevSeq and hash prevHash, and that every hash is intact.
+func Verify(events []*schema.AuditEvent, prevSeq int64, prevHash string) error {
+	for _, e := range events {
+		if e.Seq != prevSeq+1 {
+			return fmt.Errorf("%w at seq %d: expected seq %d", ErrBrokenChain, e.Seq, prevSeq+1)
+		}
+		if e.PreviousHash != prevHash {
+			return fmt.Errorf("%w at seq %d: previous hash does not match", ErrBrokenChain, e.Seq)
+		}
+		if Hash(e) != e.Hash {
+			return fmt.Errorf("%w at seq %d: hash does not match contents", ErrBrokenChain, e.Seq)
+		}
+		prevSeq, prevHash = e.Seq, e.Hash
+	}
+
+	return nil
+}
+
+// Outcome classifies an HTTP status.
+func Outcome(status int) string {
+	switch {
+	case status == 401 || status == 403:
+		return OutcomeUnauthorized
+	case status >= 500:
+		return OutcomeFailed
+	case status >= 400:
+		return OutcomeRejected
+	default:
+		return OutcomeSuccess
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func writeInt(buf *bytes.Buffer, v int64) {
+	binary.Write(buf, binary.BigEndian, v)
+}
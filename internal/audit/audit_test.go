@@ -0,0 +1,253 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// testChain returns n events chained from the genesis hash.
+func testChain(n int) []*schema.AuditEvent {
+	var events []*schema.AuditEvent
+	prevHash := GenesisHash
+
+	for i := range n {
+		e := &schema.AuditEvent{
+			Seq:          int64(i + 1),
+			RequestID:    "request",
+			Actor:        "alice",
+			Method:       http.MethodPost,
+			Route:        "/contracts/{id}/execute",
+			Path:         "/contracts/abc/execute",
+			ContractID:   "abc",
+			Function:     "transfer",
+			Status:       http.StatusOK,
+			Outcome:      OutcomeSuccess,
+			RemoteAddr:   "192.0.2.1:1234",
+			CreatedAt:    int64(1000 * (i + 1)),
+			PreviousHash: prevHash,
+		}
+		e.Hash = Hash(e)
+		events = append(events, e)
+		prevHash = e.Hash
+	}
+
+	return events
+}
+
+func TestVerifyValid(t *testing.T) {
+	events := testChain(4)
+
+	if err := Verify(events, 0, GenesisHash); err != nil {
+		t.Errorf("whole chain: %v", err)
+	}
+	if err := Verify(events[2:], events[1].Seq, events[1].Hash); err != nil {
+		t.Errorf("chain continued from seq 2: %v", err)
+	}
+	if err := Verify(nil, 0, GenesisHash); err != nil {
+		t.Errorf("empty chain: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(events []*schema.AuditEvent) []*schema.AuditEvent
+	}{
+		{
+			name: "changed field",
+			mutate: func(events []*schema.AuditEvent) []*schema.AuditEvent {
+				events[1].Actor = "mallory"
+				return events
+			},
+		},
+		{
+			// Rehashing an edited event still breaks the link to the next.
+			name: "changed and rehashed",
+			mutate: func(events []*schema.AuditEvent) []*schema.AuditEvent {
+				events[1].Status = http.StatusForbidden
+				events[1].Hash = Hash(events[1])
+				return events
+			},
+		},
+		{
+			name: "dropped event",
+			mutate: func(events []*schema.AuditEvent) []*schema.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+		},
+		{
+			name: "reordered events",
+			mutate: func(events []*schema.AuditEvent) []*schema.AuditEvent {
+				events[1], events[2] = events[2], events[1]
+				return events
+			},
+		},
+		{
+			name: "previous hash",
+			mutate: func(events []*schema.AuditEvent) []*schema.AuditEvent {
+				events[0].PreviousHash = events[2].Hash
+				events[0].Hash = Hash(events[0])
+				return events
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := tt.mutate(testChain(4))
+
+			if err := Verify(events, 0, GenesisHash); !errors.Is(err, ErrBrokenChain) {
+				t.Errorf("got %v, want %v", err, ErrBrokenChain)
+			}
+		})
+	}
+}
+
+func TestVerifyWrongStart(t *testing.T) {
+	events := testChain(3)
+
+	if err := Verify(events[1:], 0, GenesisHash); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("wrong seq: got %v, want %v", err, ErrBrokenChain)
+	}
+	if err := Verify(events[1:], 1, GenesisHash); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("wrong hash: got %v, want %v", err, ErrBrokenChain)
+	}
+}
+
+// TestHashCoversEveryField checks that no field can change without changing
+// the hash, and that length prefixes keep adjacent fields apart.
+func TestHashCoversEveryField(t *testing.T) {
+	mutations := map[string]func(e *schema.AuditEvent){
+		"seq":           func(e *schema.AuditEvent) { e.Seq++ },
+		"request_id":    func(e *schema.AuditEvent) { e.RequestID = "other" },
+		"actor":         func(e *schema.AuditEvent) { e.Actor = "bob" },
+		"method":        func(e *schema.AuditEvent) { e.Method = http.MethodDelete },
+		"route":         func(e *schema.AuditEvent) { e.Route = "/contracts/deploy" },
+		"path":          func(e *schema.AuditEvent) { e.Path = "/contracts/def/execute" },
+		"contract_id":   func(e *schema.AuditEvent) { e.ContractID = "def" },
+		"function":      func(e *schema.AuditEvent) { e.Function = "mint" },
+		"status":        func(e *schema.AuditEvent) { e.Status = http.StatusCreated },
+		"outcome":       func(e *schema.AuditEvent) { e.Outcome = OutcomeFailed },
+		"remote_addr":   func(e *schema.AuditEvent) { e.RemoteAddr = "192.0.2.2:1234" },
+		"created_at":    func(e *schema.AuditEvent) { e.CreatedAt++ },
+		"previous_hash": func(e *schema.AuditEvent) { e.PreviousHash = "0x01" },
+		"shifted field": func(e *schema.AuditEvent) {
+			e.ContractID, e.Function = e.ContractID+e.Function[:1], e.Function[1:]
+		},
+	}
+
+	want := testChain(1)[0].Hash
+	for name, mutate := range mutations {
+		t.Run(name, func(t *testing.T) {
+			e := testChain(1)[0]
+			mutate(e)
+			if Hash(e) == want {
+				t.Errorf("hash unchanged after changing %s", name)
+			}
+		})
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, OutcomeSuccess},
+		{http.StatusAccepted, OutcomeSuccess},
+		{http.StatusBadRequest, OutcomeRejected},
+		{http.StatusNotFound, OutcomeRejected},
+		{http.StatusTooManyRequests, OutcomeRejected},
+		{http.StatusUnauthorized, OutcomeUnauthorized},
+		{http.StatusForbidden, OutcomeUnauthorized},
+		{http.StatusInternalServerError, OutcomeFailed},
+		{http.StatusServiceUnavailable, OutcomeFailed},
+	}
+
+	for _, tt := range tests {
+		if got := Outcome(tt.status); got != tt.want {
+			t.Errorf("Outcome(%d): got %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	events []*schema.AuditEvent
+}
+
+func (s *memoryStore) Append(ctx context.Context, event *schema.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	store := &memoryStore{}
+
+	r := chi.NewRouter()
+	r.Use(Middleware(store))
+	r.Post("/contracts/{id}/execute", func(w http.ResponseWriter, r *http.Request) {
+		SetActor(r.Context(), "alice")
+		SetFunction(r.Context(), "transfer")
+		w.WriteHeader(http.StatusCreated)
+	})
+	r.Get("/contracts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.Get("/contracts/{id}/secret", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	r.Get("/audit/events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/contracts/abc/execute"},
+		{http.MethodGet, "/contracts/abc"},
+		{http.MethodGet, "/contracts/abc/secret"},
+		{http.MethodGet, "/audit/events"},
+	}
+	for _, req := range requests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	if len(store.events) != 3 {
+		t.Fatalf("got %d audit events, want 3 (plain reads are not audited)", len(store.events))
+	}
+
+	exec := store.events[0]
+	want := schema.AuditEvent{
+		Actor:      "alice",
+		Method:     http.MethodPost,
+		Route:      "/contracts/{id}/execute",
+		Path:       "/contracts/abc/execute",
+		ContractID: "abc",
+		Function:   "transfer",
+		Status:     http.StatusCreated,
+		Outcome:    OutcomeSuccess,
+		RemoteAddr: exec.RemoteAddr,
+		CreatedAt:  exec.CreatedAt,
+	}
+	if *exec != want {
+		t.Errorf("got %+v, want %+v", *exec, want)
+	}
+
+	if got := store.events[1].Outcome; got != OutcomeUnauthorized {
+		t.Errorf("forbidden read: got outcome %s, want %s", got, OutcomeUnauthorized)
+	}
+	if got := store.events[2].Route; got != "/audit/events" {
+		t.Errorf("admin read: got route %s, want /audit/events", got)
+	}
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// Store appends events to the audit chain, filling in Seq, PreviousHash and
+// Hash.
+type Store interface {
+	Append(ctx context.Context, event *schema.AuditEvent) error
+}
+
+// adminPrefixes are routes audited even when they only read.
+var adminPrefixes = []string{"/audit"}
+
+type contextKey struct{}
+
+// details collects what handlers deeper in the chain learn about a request,
+// such as the authenticated actor, so the middleware can record it.
+type details struct {
+	mu         sync.Mutex
+	actor      string
+	contractID string
+	function   string
+}
+
+func SetActor(ctx context.Context, actor string) {
+	update(ctx, func(d *details) { d.actor = actor })
+}
+
+func SetContract(ctx context.Context, contractID string) {
+	update(ctx, func(d *details) { d.contractID = contractID })
+}
+
+func SetFunction(ctx context.Context, function string) {
+	update(ctx, func(d *details) { d.function = function })
+}
+
+func update(ctx context.Context, fn func(*details)) {
+	d, ok := ctx.Value(contextKey{}).(*details)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(d)
+}
+
+// Middleware records every mutating request, every request refused for
+// authentication or authorization, and every admin request. It must wrap
+// the router so the matched route and URL parameters are known afterwards.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := &details{}
+			ctx := context.WithValue(r.Context(), contextKey{}, d)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if !audited(r, status) {
+				return
+			}
+
+			route := r.URL.Path
+			contractID := ""
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" {
+					route = pattern
+				}
+				contractID = rctx.URLParam("id")
+			}
+
+			d.mu.Lock()
+			event := &schema.AuditEvent{
+				RequestID:  middleware.GetReqID(r.Context()),
+				Actor:      d.actor,
+				Method:     r.Method,
+				Route:      route,
+				Path:       r.URL.Path,
+				ContractID: contractID,
+				Function:   d.function,
+				Status:     status,
+				Outcome:    Outcome(status),
+				RemoteAddr: r.RemoteAddr,
+				CreatedAt:  time.Now().UTC().UnixMilli(),
+			}
+			if d.contractID != "" {
+				event.ContractID = d.contractID
+			}
+			d.mu.Unlock()
+
+			if err := store.Append(context.WithoutCancel(r.Context()), event); err != nil {
+				logging.FromContext(r.Context()).Error("Failed to write audit event", "error", err)
+			}
+		})
+	}
+}
+
+func audited(r *http.Request, status int) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return true
+	}
+
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return true
+	}
+
+	for _, prefix := range adminPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -11,6 +11,7 @@ import (
 func GenerateJWT(priv ed25519.PrivateKey) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
 		"iss": "bff-service",
+		"sub": "bff-service",
 		"aud": "eeapi",
 		"exp": time.Now().Add(5 * time.Minute).Unix(),
 		"iat": time.Now().Unix(),
@@ -4,13 +4,19 @@ import (
 	"context"
 	"crypto/ed25519"
 	"net/http"
+	"slices"
 	"strings"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/audit"
+	"github.com/peiblow/eeapi/internal/logging"
 )
 
 type contextKey string
 
 const (
-	ContextUserIDKey contextKey = "userID"
+	ContextUserIDKey  contextKey = "userID"
+	ContextSubjectKey contextKey = "subject"
 )
 
 func JWTMiddleware(publicKey ed25519.PublicKey) func(http.Handler) http.Handler {
@@ -19,24 +25,71 @@ func JWTMiddleware(publicKey ed25519.PublicKey) func(http.Handler) http.Handler
 
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+				apperr.Write(w, r, apperr.New(apperr.CodeUnauthorized, "missing Authorization header"))
 				return
 			}
 
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+				apperr.Write(w, r, apperr.New(apperr.CodeUnauthorized, "invalid Authorization header format"))
 				return
 			}
 
 			claims, err := ParseToken(parts[1], publicKey)
 			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				apperr.Write(w, r, apperr.Wrap(apperr.CodeUnauthorized, "invalid token", err))
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), ContextUserIDKey, claims.UserID)
+			// Tokens without a user_id act for their subject, so limits,
+			// quotas and ownership never collapse into one shared identity.
+			identity := claims.UserID
+			if identity == "" {
+				identity = claims.Subject
+			}
+
+			ctx := WithIdentity(r.Context(), identity)
+			ctx = context.WithValue(ctx, ContextSubjectKey, claims.Subject)
+
+			actor := claims.Subject
+			if actor == "" {
+				actor = claims.UserID
+			}
+			audit.SetActor(ctx, actor)
+
+			ctx = logging.With(ctx, "user_id", identity)
+			logging.AddCompletion(ctx, "user_id", identity)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// Identity returns the authenticated user a request or job runs on behalf of.
+func Identity(ctx context.Context) string {
+	identity, _ := ctx.Value(ContextUserIDKey).(string)
+	return identity
+}
+
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, ContextUserIDKey, identity)
+}
+
+// Subject returns the subject of the request's JWT.
+func Subject(ctx context.Context) string {
+	subject, _ := ctx.Value(ContextSubjectKey).(string)
+	return subject
+}
+
+// RequireSubject only lets through requests whose JWT subject is one of
+// subjects. It must run after JWTMiddleware.
+func RequireSubject(subjects []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(subjects, Subject(r.Context())) {
+				apperr.Write(w, r, apperr.New(apperr.CodeForbidden, "admin access required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
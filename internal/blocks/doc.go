@@ -0,0 +1,42 @@
+// Package blocks builds and verifies the per-contract block chains.
+//
+// # Hash versions
+//
+// Every block records the hash_version its hash was computed with, and
+// verification dispatches on it, so the format can evolve without
+// invalidating old blocks. A block without a version is version 1.
+//
+// Version 1 (legacy) hashes
+//
+//	journal_hash = SHA-256(journal || decimal(timestamp))
+//	hash         = SHA-256(timestamp "|" previous_hash "|" journal_hash "|"
+//	                       contract_id "|" function_name "|" artifact_hash
+//	                       ["|" journal_root])
+//
+// where journal is the encoding/json output for the journal and the block
+// hash input is the decimal timestamp and the fields joined by "|". The
+// journal root is appended only when the block has one. Neither input is
+// unambiguous, and the journal bytes depend on encoding/json, which is why
+// version 2 exists.
+//
+// Version 2 (canonical) hashes
+//
+//	journal_hash = SHA-256("EEAPI-JOURNAL" || u8(2) || str(journal) || i64(timestamp))
+//	hash         = SHA-256("EEAPI-BLOCK" || u8(2) ||
+//	                       i64(block_index) || i64(timestamp) ||
+//	                       str(previous_hash) || str(journal_hash) ||
+//	                       str(journal_root) || str(contract_id) ||
+//	                       str(function_name) || str(artifact_hash) ||
+//	                       str(args_hash))
+//
+// where the tags are the raw ASCII bytes, u8 is one byte, i64 is an 8 byte
+// big-endian two's complement integer and str is a 4 byte big-endian length
+// followed by the UTF-8 bytes. Hashes are written as "0x" plus lowercase hex
+// and are encoded as such inside the preimage. journal is the RFC 8785
+// canonical JSON of the journal array; journal_root is the Merkle root over
+// the canonical JSON of each entry (see package merkle) and args_hash is the
+// SHA-256 of the canonical JSON of the call arguments.
+//
+// The block signature is the node's ed25519 signature over the raw 32 byte
+// block hash.
+package blocks
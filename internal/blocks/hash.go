@@ -0,0 +1,130 @@
+package blocks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/peiblow/eeapi/internal/canonicaljson"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+const (
+	HashV1 = 1
+	HashV2 = 2
+
+	CurrentHashVersion = HashV2
+)
+
+// Version returns the hash version of a block, treating blocks stored before
+// versioning as version 1.
+func Version(b *schema.Block) int {
+	if b.HashVersion == 0 {
+		return HashV1
+	}
+	return b.HashVersion
+}
+
+// EncodeJournal returns the journal bytes that are hashed, encrypted and
+// stored for the given hash version.
+func EncodeJournal(version int, journal any) ([]byte, error) {
+	switch version {
+	case HashV1:
+		return json.Marshal(journal)
+	case HashV2:
+		return canonicaljson.Marshal(journal)
+	default:
+		return nil, fmt.Errorf("unsupported hash version %d", version)
+	}
+}
+
+// JournalHash hashes the encoded journal of a block.
+func JournalHash(version int, journalBytes []byte, timestamp int64) (string, error) {
+	var sum [32]byte
+
+	switch version {
+	case HashV1:
+		sum = sha256.Sum256(append(append([]byte{}, journalBytes...), []byte(fmt.Sprintf("%d", timestamp))...))
+	case HashV2:
+		var buf bytes.Buffer
+		buf.WriteString("EEAPI-JOURNAL")
+		buf.WriteByte(HashV2)
+		writeString(&buf, string(journalBytes))
+		writeInt(&buf, timestamp)
+		sum = sha256.Sum256(buf.Bytes())
+	default:
+		return "", fmt.Errorf("unsupported hash version %d", version)
+	}
+
+	return "0x" + hex.EncodeToString(sum[:]), nil
+}
+
+// ComputeHash returns the raw block hash according to the block's version.
+func ComputeHash(b *schema.Block) ([]byte, error) {
+	var sum [32]byte
+
+	switch Version(b) {
+	case HashV1:
+		// Blocks written before the artifact hash was stored were hashed
+		// with the artifact hash the VM echoes back, which is the contract ID.
+		artifactHash := b.ArtifactHash
+		if artifactHash == "" {
+			artifactHash = b.ContractID
+		}
+
+		data := fmt.Sprintf(
+			"%d|%s|%s|%s|%s|%s",
+			b.Timestamp,
+			b.PreviousHash,
+			b.JournalHash,
+			b.ContractID,
+			b.FunctionName,
+			artifactHash,
+		)
+		if b.JournalRoot != "" {
+			data += "|" + b.JournalRoot
+		}
+		sum = sha256.Sum256([]byte(data))
+	case HashV2:
+		var buf bytes.Buffer
+		buf.WriteString("EEAPI-BLOCK")
+		buf.WriteByte(HashV2)
+		writeInt(&buf, b.BlockIndex)
+		writeInt(&buf, b.Timestamp)
+		writeString(&buf, b.PreviousHash)
+		writeString(&buf, b.JournalHash)
+		writeString(&buf, b.JournalRoot)
+		writeString(&buf, b.ContractID)
+		writeString(&buf, b.FunctionName)
+		writeString(&buf, b.ArtifactHash)
+		writeString(&buf, b.ArgsHash)
+		sum = sha256.Sum256(buf.Bytes())
+	default:
+		return nil, fmt.Errorf("unsupported hash version %d", b.HashVersion)
+	}
+
+	return sum[:], nil
+}
+
+// ArgsHash hashes the call arguments recorded on a block.
+func ArgsHash(version int, args any) (string, error) {
+	argsBytes, err := EncodeJournal(version, args)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(argsBytes)
+	return "0x" + hex.EncodeToString(sum[:]), nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func writeInt(buf *bytes.Buffer, v int64) {
+	binary.Write(buf, binary.BigEndian, v)
+}
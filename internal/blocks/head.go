@@ -0,0 +1,39 @@
+package blocks
+
+import (
+	"bytes"
+	"crypto/ed25519"
+
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// HeadSigningBytes encodes a contract head the way the node signs it, in the
+// same length-prefixed form as version 2 block hashes.
+func HeadSigningBytes(head *schema.ContractHead) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("EEAPI-HEAD")
+	writeString(&buf, head.ContractID)
+	writeInt(&buf, head.BlockIndex)
+	writeString(&buf, head.BlockHash)
+	return buf.Bytes()
+}
+
+func SignHead(head *schema.ContractHead, priv ed25519.PrivateKey) {
+	head.Signature = ed25519.Sign(priv, HeadSigningBytes(head))
+}
+
+func VerifyHead(head *schema.ContractHead, pub ed25519.PublicKey) bool {
+	return ed25519.Verify(pub, HeadSigningBytes(head), head.Signature)
+}
+
+// NewHead returns the signed head record for the given last block.
+func NewHead(b *schema.Block, priv ed25519.PrivateKey, updatedAt int64) *schema.ContractHead {
+	head := &schema.ContractHead{
+		ContractID: b.ContractID,
+		BlockIndex: b.BlockIndex,
+		BlockHash:  b.Hash,
+		UpdatedAt:  updatedAt,
+	}
+	SignHead(head, priv)
+	return head
+}
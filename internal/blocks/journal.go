@@ -0,0 +1,34 @@
+package blocks
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/peiblow/eeapi/internal/merkle"
+)
+
+// JournalLeaves splits a JSON encoded journal into its entries, keeping each
+// entry's bytes exactly as they appear in the journal.
+func JournalLeaves(journalBytes []byte) ([][]byte, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(journalBytes, &entries); err != nil {
+		return nil, err
+	}
+
+	leaves := make([][]byte, len(entries))
+	for i, entry := range entries {
+		leaves[i] = entry
+	}
+
+	return leaves, nil
+}
+
+// JournalRoot returns the hex encoded Merkle root over the journal entries.
+func JournalRoot(journalBytes []byte) (string, error) {
+	leaves, err := JournalLeaves(journalBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return "0x" + hex.EncodeToString(merkle.Root(leaves)), nil
+}
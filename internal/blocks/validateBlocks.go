@@ -1,34 +1,249 @@
 package blocks
 
 import (
+	"bytes"
 	"crypto/ed25519"
-	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/peiblow/eeapi/internal/schema"
 )
 
+var (
+	ErrBlockIndex       = errors.New("invalid block index")
+	ErrContractMismatch = errors.New("contract id does not match previous block")
+	ErrPreviousHash     = errors.New("invalid previous hash")
+	ErrTimestamp        = errors.New("invalid timestamp")
+	ErrHashVersion      = errors.New("unsupported hash version")
+	ErrJournalHash      = errors.New("invalid journal hash")
+	ErrJournalRoot      = errors.New("invalid journal root")
+	ErrMalformedHash    = errors.New("malformed block hash")
+	ErrBlockHash        = errors.New("block hash does not match its contents")
+	ErrSignature        = errors.New("invalid block signature")
+	ErrGenesis          = errors.New("invalid genesis block")
+)
+
+// VerificationError describes why a block failed verification. It wraps one
+// of the Err* kinds above so callers can match it with errors.Is.
+type VerificationError struct {
+	Kind       error
+	ContractID string
+	BlockIndex int64
+	Expected   string
+	Got        string
+}
+
+func (e *VerificationError) Error() string {
+	msg := fmt.Sprintf("block %s#%d: %s", e.ContractID, e.BlockIndex, e.Kind)
+	if e.Expected != "" || e.Got != "" {
+		msg += fmt.Sprintf(": expected %s, got %s", e.Expected, e.Got)
+	}
+	return msg
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Kind
+}
+
+func verificationError(kind error, b *schema.Block, expected, got string) error {
+	return &VerificationError{
+		Kind:       kind,
+		ContractID: b.ContractID,
+		BlockIndex: b.BlockIndex,
+		Expected:   expected,
+		Got:        got,
+	}
+}
+
+const (
+	GenesisHash      = "0xGENESIS_HASH"
+	GenesisSignature = "GENESIS_SIGNATURE"
+)
+
+func IsGenesis(b *schema.Block) bool {
+	return b.FunctionName == "genesis" && b.Hash == GenesisHash
+}
+
+// VerifyGenesis checks the placeholder block every contract chain starts with.
+func VerifyGenesis(b schema.Block) error {
+	switch {
+	case b.BlockIndex != 1:
+		return verificationError(ErrGenesis, &b, "index 1", fmt.Sprintf("index %d", b.BlockIndex))
+	case b.Hash != GenesisHash:
+		return verificationError(ErrGenesis, &b, GenesisHash, b.Hash)
+	case b.PreviousHash != "0":
+		return verificationError(ErrGenesis, &b, "previous hash 0", b.PreviousHash)
+	case string(b.Signature) != GenesisSignature:
+		return verificationError(ErrGenesis, &b, GenesisSignature, string(b.Signature))
+	}
+	return nil
+}
+
+// VerifyBlock checks that newBlock correctly extends lastBlock: index and
+// contract linkage, timestamp order, the journal commitments, the block hash
+// recomputed from its fields and the node signature over it. journalBytes is
+// the decrypted journal; when it is nil the journal commitments are skipped,
+// which lets auditors without the node key still check the chain.
 func VerifyBlock(lastBlock, newBlock schema.Block, journalBytes []byte, pub ed25519.PublicKey) error {
+	if err := verifyContents(lastBlock, newBlock, journalBytes); err != nil {
+		return err
+	}
+
+	hashBytes, _ := hex.DecodeString(strings.TrimPrefix(newBlock.Hash, "0x"))
+	if !ed25519.Verify(pub, hashBytes, newBlock.Signature) {
+		return verificationError(ErrSignature, &newBlock, "", "")
+	}
+
+	return nil
+}
+
+// verifyContents runs every check of VerifyBlock except the signature.
+func verifyContents(lastBlock, newBlock schema.Block, journalBytes []byte) error {
+	b := &newBlock
+
+	if newBlock.BlockIndex != lastBlock.BlockIndex+1 {
+		return verificationError(ErrBlockIndex, b, fmt.Sprint(lastBlock.BlockIndex+1), fmt.Sprint(newBlock.BlockIndex))
+	}
+
+	if newBlock.ContractID != lastBlock.ContractID {
+		return verificationError(ErrContractMismatch, b, lastBlock.ContractID, newBlock.ContractID)
+	}
+
 	if newBlock.PreviousHash != lastBlock.Hash {
-		return fmt.Errorf("invalid previous hash: expected %s, got %s", lastBlock.Hash, newBlock.PreviousHash)
+		return verificationError(ErrPreviousHash, b, lastBlock.Hash, newBlock.PreviousHash)
 	}
 
 	if newBlock.Timestamp <= lastBlock.Timestamp {
-		return fmt.Errorf("invalid timestamp: new block timestamp %d is not greater than last block timestamp %d", newBlock.Timestamp, lastBlock.Timestamp)
+		return verificationError(ErrTimestamp, b, fmt.Sprintf("> %d", lastBlock.Timestamp), fmt.Sprint(newBlock.Timestamp))
 	}
 
-	journalHashRaw := sha256.Sum256(append(journalBytes, []byte(fmt.Sprintf("%d", newBlock.Timestamp))...))
-	journalHash := "0x" + hex.EncodeToString(journalHashRaw[:])
-	if journalHash != newBlock.JournalHash {
-		return fmt.Errorf("invalid journal hash: expected %s, got %s", newBlock.JournalHash, journalHash)
+	version := Version(b)
+	if version != HashV1 && version != HashV2 {
+		return verificationError(ErrHashVersion, b, "", fmt.Sprint(version))
 	}
 
-	hashBytes, _ := hex.DecodeString(strings.TrimPrefix(newBlock.Hash, "0x"))
-	if !ed25519.Verify(pub, hashBytes, newBlock.Signature) {
-		return fmt.Errorf("invalid block signature")
+	if journalBytes != nil {
+		journalHash, err := JournalHash(version, journalBytes, newBlock.Timestamp)
+		if err != nil {
+			return verificationError(ErrJournalHash, b, newBlock.JournalHash, err.Error())
+		}
+		if journalHash != newBlock.JournalHash {
+			return verificationError(ErrJournalHash, b, newBlock.JournalHash, journalHash)
+		}
+
+		if newBlock.JournalRoot != "" {
+			journalRoot, err := JournalRoot(journalBytes)
+			if err != nil {
+				return verificationError(ErrJournalRoot, b, newBlock.JournalRoot, err.Error())
+			}
+			if journalRoot != newBlock.JournalRoot {
+				return verificationError(ErrJournalRoot, b, newBlock.JournalRoot, journalRoot)
+			}
+		}
+	}
+
+	// Only the lowercase encoding the node writes is accepted, so a block
+	// hash cannot be restated in another form and still verify.
+	hashBytes, err := hex.DecodeString(strings.TrimPrefix(newBlock.Hash, "0x"))
+	if err != nil || len(hashBytes) != 32 || newBlock.Hash != "0x"+hex.EncodeToString(hashBytes) {
+		return verificationError(ErrMalformedHash, b, "", newBlock.Hash)
+	}
+
+	computed, err := ComputeHash(b)
+	if err != nil {
+		return verificationError(ErrHashVersion, b, "", err.Error())
+	}
+	if !bytes.Equal(computed, hashBytes) {
+		return verificationError(ErrBlockHash, b, "0x"+hex.EncodeToString(computed), newBlock.Hash)
+	}
+
+	return nil
+}
+
+// VerifyLinks checks that a chain ordered by block index hashes and links
+// correctly, without checking block signatures or journals. Together with a
+// signed record of the head hash it proves the whole chain unchanged, even
+// for imported blocks signed by another node.
+func VerifyLinks(chain []*schema.Block) error {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	if err := VerifyGenesis(*chain[0]); err != nil {
+		return err
+	}
+
+	for i := 1; i < len(chain); i++ {
+		if err := verifyContents(*chain[i-1], *chain[i], nil); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// JournalFunc returns the decrypted journal of a block, or nil to skip the
+// journal checks for it.
+type JournalFunc func(b *schema.Block) ([]byte, error)
+
+// VerifyChain verifies a contract chain ordered by block index, starting at
+// its genesis block, and returns the first failure.
+func VerifyChain(chain []*schema.Block, pub ed25519.PublicKey, journal JournalFunc) error {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	if err := VerifyGenesis(*chain[0]); err != nil {
+		return err
+	}
+
+	for i := 1; i < len(chain); i++ {
+		var journalBytes []byte
+		if journal != nil {
+			var err error
+			if journalBytes, err = journal(chain[i]); err != nil {
+				return fmt.Errorf("block %s#%d: %w", chain[i].ContractID, chain[i].BlockIndex, err)
+			}
+		}
+
+		if err := VerifyBlock(*chain[i-1], *chain[i], journalBytes, pub); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Audit verifies every block of a chain instead of stopping at the first
+// failure, and returns all failures in chain order. A broken block is still
+// used as the predecessor of the next one, so one edited block shows up once
+// rather than breaking every block after it.
+func Audit(chain []*schema.Block, pub ed25519.PublicKey, journal JournalFunc) []error {
+	var failures []error
+	if len(chain) == 0 {
+		return nil
+	}
+
+	if err := VerifyGenesis(*chain[0]); err != nil {
+		failures = append(failures, err)
+	}
+
+	for i := 1; i < len(chain); i++ {
+		var journalBytes []byte
+		if journal != nil {
+			var err error
+			if journalBytes, err = journal(chain[i]); err != nil {
+				failures = append(failures, fmt.Errorf("block %s#%d: %w", chain[i].ContractID, chain[i].BlockIndex, err))
+				journalBytes = nil
+			}
+		}
+
+		if err := VerifyBlock(*chain[i-1], *chain[i], journalBytes, pub); err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,277 @@
+package blocks
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+var testKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+// testChain returns a valid chain of n blocks after genesis, signed with
+// testKey, and the plaintext journal of each block by index.
+func testChain(t testing.TB, n int) ([]*schema.Block, map[int64][]byte) {
+	t.Helper()
+
+	chain := []*schema.Block{{
+		BlockIndex:   1,
+		Hash:         GenesisHash,
+		Timestamp:    1000,
+		PreviousHash: "0",
+		Signature:    []byte(GenesisSignature),
+		ContractID:   "contract",
+		FunctionName: "genesis",
+	}}
+	journals := make(map[int64][]byte)
+
+	for i := 0; i < n; i++ {
+		last := chain[len(chain)-1]
+		block := &schema.Block{
+			BlockIndex:   last.BlockIndex + 1,
+			Timestamp:    last.Timestamp + 1000,
+			PreviousHash: last.Hash,
+			ContractID:   last.ContractID,
+			FunctionName: "transfer",
+			ArtifactHash: "0xartifact",
+			HashVersion:  CurrentHashVersion,
+		}
+
+		journalBytes, err := EncodeJournal(block.HashVersion, []any{
+			map[string]any{"op": "set", "key": "balance", "value": i},
+			map[string]any{"op": "emit", "event": "transferred"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block.ArgsHash, err = ArgsHash(block.HashVersion, map[string]any{"amount": i}); err != nil {
+			t.Fatal(err)
+		}
+		if block.JournalHash, err = JournalHash(block.HashVersion, journalBytes, block.Timestamp); err != nil {
+			t.Fatal(err)
+		}
+		if block.JournalRoot, err = JournalRoot(journalBytes); err != nil {
+			t.Fatal(err)
+		}
+
+		sign(t, block)
+		chain = append(chain, block)
+		journals[block.BlockIndex] = journalBytes
+	}
+
+	return chain, journals
+}
+
+// sign sets a block's hash and signature from its current fields.
+func sign(t testing.TB, b *schema.Block) {
+	t.Helper()
+
+	hash, err := ComputeHash(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Hash = "0x" + hex.EncodeToString(hash)
+	b.Signature = ed25519.Sign(testKey, hash)
+}
+
+func journalFunc(journals map[int64][]byte) JournalFunc {
+	return func(b *schema.Block) ([]byte, error) {
+		return journals[b.BlockIndex], nil
+	}
+}
+
+func TestVerifyChainValid(t *testing.T) {
+	chain, journals := testChain(t, 3)
+	pub := testKey.Public().(ed25519.PublicKey)
+
+	if err := VerifyLinks(chain); err != nil {
+		t.Errorf("VerifyLinks: %v", err)
+	}
+	if err := VerifyChain(chain, pub, journalFunc(journals)); err != nil {
+		t.Errorf("VerifyChain: %v", err)
+	}
+	if failures := Audit(chain, pub, journalFunc(journals)); len(failures) != 0 {
+		t.Errorf("Audit: %v", failures)
+	}
+}
+
+func TestVerificationErrorKinds(t *testing.T) {
+	tests := []struct {
+		kind error
+		// genesis mutates the genesis block instead of the last one.
+		genesis bool
+		mutate  func(b *schema.Block)
+		// resign recomputes the hash and signature after mutate, so only
+		// the check for kind can catch the change.
+		resign bool
+		// links is false for kinds VerifyLinks does not check.
+		links bool
+	}{
+		{kind: ErrBlockIndex, mutate: func(b *schema.Block) { b.BlockIndex++ }, resign: true, links: true},
+		{kind: ErrContractMismatch, mutate: func(b *schema.Block) { b.ContractID = "other" }, resign: true, links: true},
+		{kind: ErrPreviousHash, mutate: func(b *schema.Block) { b.PreviousHash = "0x00" }, resign: true, links: true},
+		{kind: ErrTimestamp, mutate: func(b *schema.Block) { b.Timestamp -= 1000 }, resign: true, links: true},
+		{kind: ErrHashVersion, mutate: func(b *schema.Block) { b.HashVersion = 9 }, links: true},
+		{kind: ErrJournalHash, mutate: func(b *schema.Block) { b.JournalHash = "0x00" }, resign: true},
+		{kind: ErrJournalRoot, mutate: func(b *schema.Block) { b.JournalRoot = "0x00" }, resign: true},
+		{kind: ErrMalformedHash, mutate: func(b *schema.Block) { b.Hash = "0xnothex" }, links: true},
+		{kind: ErrMalformedHash, mutate: func(b *schema.Block) { b.Hash = "0X" + b.Hash[2:] }, links: true},
+		{kind: ErrMalformedHash, mutate: func(b *schema.Block) { b.Hash = strings.ToUpper(b.Hash[2:]) }, links: true},
+		{kind: ErrMalformedHash, mutate: func(b *schema.Block) { b.Hash = "0x" + strings.ToUpper(b.Hash[2:]) }, links: true},
+		{kind: ErrBlockHash, mutate: func(b *schema.Block) { b.FunctionName = "mint" }, links: true},
+		{kind: ErrSignature, mutate: func(b *schema.Block) { b.Signature[0] ^= 0xff }},
+		{kind: ErrGenesis, genesis: true, mutate: func(b *schema.Block) { b.Signature = nil }, links: true},
+	}
+
+	pub := testKey.Public().(ed25519.PublicKey)
+
+	for _, tt := range tests {
+		t.Run(tt.kind.Error(), func(t *testing.T) {
+			chain, journals := testChain(t, 3)
+
+			target := chain[len(chain)-1]
+			if tt.genesis {
+				target = chain[0]
+			}
+			tt.mutate(target)
+			if tt.resign {
+				sign(t, target)
+			}
+
+			if !tt.genesis {
+				last, block := *chain[len(chain)-2], *target
+				err := VerifyBlock(last, block, journals[block.BlockIndex], pub)
+				assertKind(t, "VerifyBlock", err, tt.kind)
+			}
+
+			err := VerifyLinks(chain)
+			if tt.links {
+				assertKind(t, "VerifyLinks", err, tt.kind)
+			} else if err != nil {
+				t.Errorf("VerifyLinks: unexpected error %v", err)
+			}
+
+			err = VerifyChain(chain, pub, journalFunc(journals))
+			assertKind(t, "VerifyChain", err, tt.kind)
+
+			failures := Audit(chain, pub, journalFunc(journals))
+			if len(failures) != 1 {
+				t.Fatalf("Audit: got %d failures %v, want 1", len(failures), failures)
+			}
+			assertKind(t, "Audit", failures[0], tt.kind)
+		})
+	}
+}
+
+func assertKind(t *testing.T, fn string, err error, kind error) {
+	t.Helper()
+
+	if !errors.Is(err, kind) {
+		t.Errorf("%s: got %v, want %v", fn, err, kind)
+		return
+	}
+
+	var verr *VerificationError
+	if !errors.As(err, &verr) {
+		t.Errorf("%s: %v is not a *VerificationError", fn, err)
+	}
+}
+
+func TestAuditReportsEveryBrokenBlock(t *testing.T) {
+	chain, journals := testChain(t, 4)
+	pub := testKey.Public().(ed25519.PublicKey)
+
+	chain[2].FunctionName = "mint"
+	chain[4].Signature[0] ^= 0xff
+
+	failures := Audit(chain, pub, journalFunc(journals))
+	if len(failures) != 2 {
+		t.Fatalf("got %d failures %v, want 2", len(failures), failures)
+	}
+	assertKind(t, "Audit", failures[0], ErrBlockHash)
+	assertKind(t, "Audit", failures[1], ErrSignature)
+}
+
+func TestVerifyChainJournalError(t *testing.T) {
+	chain, _ := testChain(t, 2)
+	pub := testKey.Public().(ed25519.PublicKey)
+
+	errDecrypt := errors.New("decrypt failed")
+	journal := func(b *schema.Block) ([]byte, error) { return nil, errDecrypt }
+
+	if err := VerifyChain(chain, pub, journal); !errors.Is(err, errDecrypt) {
+		t.Errorf("VerifyChain: got %v, want %v", err, errDecrypt)
+	}
+	if failures := Audit(chain, pub, journal); len(failures) != 2 {
+		t.Errorf("Audit: got %d failures %v, want 2", len(failures), failures)
+	}
+}
+
+// FuzzVerifyBlock changes one field of a valid block and checks that
+// VerifyBlock rejects every change to a field it covers, with a
+// VerificationError, and never panics.
+func FuzzVerifyBlock(f *testing.F) {
+	const fields = 11
+
+	for field := range fields {
+		f.Add(uint8(field), "", int64(0))
+		f.Add(uint8(field), "0xff", int64(-1))
+		f.Add(uint8(field), fmt.Sprint(field), int64(1<<40))
+	}
+
+	chain, journals := testChain(f, 1)
+	pub := testKey.Public().(ed25519.PublicKey)
+	last, valid := *chain[0], *chain[1]
+	journal := journals[valid.BlockIndex]
+
+	f.Fuzz(func(t *testing.T, field uint8, s string, n int64) {
+		block := valid
+		block.Signature = append([]byte(nil), valid.Signature...)
+
+		switch field % fields {
+		case 0:
+			block.BlockIndex = n
+		case 1:
+			block.Hash = s
+		case 2:
+			block.Timestamp = n
+		case 3:
+			block.PreviousHash = s
+		case 4:
+			block.JournalHash = s
+		case 5:
+			block.Signature = []byte(s)
+		case 6:
+			block.ContractID = s
+		case 7:
+			block.FunctionName = s
+		case 8:
+			block.ArgsHash = s
+		case 9:
+			block.JournalRoot = s
+		case 10:
+			block.ArtifactHash = s
+		}
+
+		err := VerifyBlock(last, block, journal, pub)
+		if reflect.DeepEqual(block, valid) {
+			if err != nil {
+				t.Fatalf("unchanged block rejected: %v", err)
+			}
+			return
+		}
+
+		if err == nil {
+			t.Fatalf("changed field %d to %q/%d but the block verified", field%fields, s, n)
+		}
+		var verr *VerificationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("%v is not a *VerificationError", err)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package canonicaljson
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// TestNumbers checks the number serialization samples of RFC 8785,
+// appendix B, given as the IEEE 754 bits of each double.
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		bits string
+		want string
+	}{
+		{"0000000000000000", "0"},
+		{"8000000000000000", "0"},
+		{"0000000000000001", "5e-324"},
+		{"8000000000000001", "-5e-324"},
+		{"7fefffffffffffff", "1.7976931348623157e+308"},
+		{"ffefffffffffffff", "-1.7976931348623157e+308"},
+		{"4340000000000000", "9007199254740992"},
+		{"c340000000000000", "-9007199254740992"},
+		{"4430000000000000", "295147905179352830000"},
+		{"44b52d02c7e14af5", "9.999999999999997e+22"},
+		{"44b52d02c7e14af6", "1e+23"},
+		{"44b52d02c7e14af7", "1.0000000000000001e+23"},
+		{"444b1ae4d6e2ef4e", "999999999999999700000"},
+		{"444b1ae4d6e2ef4f", "999999999999999900000"},
+		{"444b1ae4d6e2ef50", "1e+21"},
+		{"3eb0c6f7a0b5ed8c", "9.999999999999997e-7"},
+		{"3eb0c6f7a0b5ed8d", "0.000001"},
+		{"41b3de4355555553", "333333333.3333332"},
+		{"41b3de4355555554", "333333333.33333325"},
+		{"41b3de4355555555", "333333333.3333333"},
+		{"41b3de4355555556", "333333333.3333334"},
+		{"41b3de4355555557", "333333333.33333343"},
+		{"becbf647612f3696", "-0.0000033333333333333333"},
+		{"43143ff3c1cb0959", "1424953923781206.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bits, func(t *testing.T) {
+			raw, err := hex.DecodeString(tt.bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := math.Float64frombits(binary.BigEndian.Uint64(raw))
+
+			got, err := formatNumber(f)
+			if err != nil {
+				t.Fatalf("formatNumber: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("formatNumber: got %s, want %s", got, tt.want)
+			}
+
+			// The same double written out by Go must canonicalize the same.
+			out, err := Transform([]byte(strconv.FormatFloat(f, 'g', -1, 64)))
+			if err != nil {
+				t.Fatalf("Transform: %v", err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("Transform: got %s, want %s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumbersNotFinite(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if s, err := formatNumber(f); err == nil {
+			t.Errorf("formatNumber(%v): got %s, want an error", f, s)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			// RFC 8785, section 3.2.2.
+			name: "rfc8785 sample",
+			input: `{
+				"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+				"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+				"literals": [null, true, false]
+			}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			// RFC 8785, section 3.2.3: members are sorted by UTF-16 code
+			// units, so the emoji's surrogates sort before U+FB33.
+			name: "rfc8785 sorting",
+			input: `{
+				"\u20ac": "Euro Sign",
+				"\r": "Carriage Return",
+				"\ufb33": "Hebrew Letter Dalet With Dagesh",
+				"1": "One",
+				"\ud83d\ude00": "Emoji: Grinning Face",
+				"\u0080": "Control",
+				"\u00f6": "Latin Small Letter O With Diaeresis"
+			}`,
+			want: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\"," +
+				"\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name:  "nested",
+			input: `{"b": {"z": 1, "a": [2, {"y": 0.5, "x": -0}]}, "a": ""}`,
+			want:  `{"a":"","b":{"a":[2,{"x":0,"y":0.5}],"z":1}}`,
+		},
+		{
+			name:  "control characters",
+			input: `"\u0000\u001f\b\f\t"`,
+			want:  `"\u0000\u001f\b\f\t"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transform([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Transform: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Transform:\ngot  %s\nwant %s", got, tt.want)
+			}
+
+			again, err := Transform(got)
+			if err != nil {
+				t.Fatalf("Transform of canonical output: %v", err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("Transform is not idempotent:\ngot  %s\nwant %s", again, got)
+			}
+		})
+	}
+}
+
+func TestTransformInvalid(t *testing.T) {
+	tests := []string{
+		``,
+		`{"a":}`,
+		`{"a": 1} {"b": 2}`,
+		`[1] 2`,
+		`1e400`,
+	}
+
+	for _, input := range tests {
+		if got, err := Transform([]byte(input)); err == nil {
+			t.Errorf("Transform(%q): got %s, want an error", input, got)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	got, err := Marshal(map[string]any{
+		"value": 1.5,
+		"key":   "balance",
+		"op":    "set",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"key":"balance","op":"set","value":1.5}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/peiblow/eeapi/internal/merkle"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+var (
+	ErrRootMismatch     = errors.New("checkpoint root does not match its heads")
+	ErrHashMismatch     = errors.New("checkpoint hash does not match its contents")
+	ErrInvalidSignature = errors.New("invalid checkpoint signature")
+)
+
+// Leaf encodes a chain head as a Merkle leaf: contract ID, block index and
+// block hash, strings as a big-endian uint32 length plus bytes and the index
+// as a big-endian int64.
+func Leaf(head schema.CheckpointHead) []byte {
+	var buf bytes.Buffer
+	writeString(&buf, head.ContractID)
+	binary.Write(&buf, binary.BigEndian, head.BlockIndex)
+	writeString(&buf, head.BlockHash)
+	return buf.Bytes()
+}
+
+func Leaves(heads []schema.CheckpointHead) [][]byte {
+	leaves := make([][]byte, len(heads))
+	for i, head := range heads {
+		leaves[i] = Leaf(head)
+	}
+	return leaves
+}
+
+// Hash returns the SHA-256 the node signs for a checkpoint. It chains each
+// checkpoint to the previous one.
+func Hash(cp *schema.Checkpoint) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("eeapi-checkpoint")
+	binary.Write(&buf, binary.BigEndian, cp.Seq)
+	writeString(&buf, cp.Root)
+	writeString(&buf, cp.PreviousHash)
+	binary.Write(&buf, binary.BigEndian, int64(cp.HeadCount))
+	binary.Write(&buf, binary.BigEndian, cp.CreatedAt)
+
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:]
+}
+
+// Seal fills in the root, hash and signature of a checkpoint whose heads,
+// sequence, previous hash and timestamp are set.
+func Seal(cp *schema.Checkpoint, priv ed25519.PrivateKey) {
+	cp.HeadCount = len(cp.Heads)
+	cp.Root = encode(merkle.Root(Leaves(cp.Heads)))
+
+	hash := Hash(cp)
+	cp.Hash = encode(hash)
+	cp.Signature = ed25519.Sign(priv, hash)
+}
+
+// Verify checks a checkpoint's hash and signature, and its root when the
+// heads are included.
+func Verify(cp *schema.Checkpoint, pub ed25519.PublicKey) error {
+	if len(cp.Heads) > 0 && encode(merkle.Root(Leaves(cp.Heads))) != cp.Root {
+		return ErrRootMismatch
+	}
+
+	hash := Hash(cp)
+	if encode(hash) != cp.Hash {
+		return ErrHashMismatch
+	}
+
+	if !ed25519.Verify(pub, hash, cp.Signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func encode(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
@@ -0,0 +1,92 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// Exporter publishes checkpoints outside the database, so rewriting the
+// database alone cannot hide a rewritten history.
+type Exporter interface {
+	Export(ctx context.Context, cp *schema.Checkpoint) error
+}
+
+// FileExporter appends checkpoints as JSON lines to a file opened in append
+// mode. Pointing it at append-only storage makes the log immutable.
+type FileExporter struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileExporter(path string) *FileExporter {
+	return &FileExporter{path: path}
+}
+
+func (e *FileExporter) Export(ctx context.Context, cp *schema.Checkpoint) error {
+	line, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	file, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// HTTPExporter posts checkpoints to an external timestamping or
+// transparency service.
+type HTTPExporter struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPExporter(url string) *HTTPExporter {
+	return &HTTPExporter{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *HTTPExporter) Export(ctx context.Context, cp *schema.Checkpoint) error {
+	body, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("timestamping sink returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
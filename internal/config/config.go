@@ -1,8 +1,71 @@
 package config
 
+import (
+	"time"
+
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/outbox"
+	"github.com/peiblow/eeapi/internal/ratelimit"
+	"github.com/peiblow/eeapi/internal/retry"
+	"github.com/peiblow/eeapi/internal/tracing"
+	"github.com/peiblow/eeapi/internal/webhook"
+)
+
 type Config struct {
 	Addr string
 	DB   DBConfig
+
+	IdempotencyRetention time.Duration
+	JobWorkers           int
+	Webhook              webhook.Config
+	Outbox               outbox.Config
+	Checkpoint           CheckpointConfig
+	Watchdog             WatchdogConfig
+	Quota                QuotaConfig
+	RateLimit            ratelimit.Config
+	Tracing              tracing.Config
+	Logging              logging.Config
+
+	// RequestTimeout bounds how long a handler may run. The server's write
+	// timeout is derived from it so a handler that hits it can still send
+	// its error response.
+	RequestTimeout time.Duration
+
+	// ShutdownTimeout bounds how long a shutdown waits for in-flight
+	// requests, jobs and webhook deliveries to drain.
+	ShutdownTimeout time.Duration
+
+	// Reconnect paces the retries for Postgres at startup and for the SVM
+	// whenever it is unreachable.
+	Reconnect retry.Backoff
+
+	// AdminSubjects are the JWT subjects allowed to use the admin endpoints.
+	AdminSubjects []string
+
+	// NotifyReplicas relays committed blocks between eeapi replicas through
+	// Postgres LISTEN/NOTIFY so every replica can stream them.
+	NotifyReplicas bool
+}
+
+// CheckpointConfig controls the global checkpoint chain. A zero Interval
+// disables it; File and URL are optional export targets.
+type CheckpointConfig struct {
+	Interval time.Duration
+	File     string
+	URL      string
+}
+
+// QuotaConfig holds the default limits for identities without a stored
+// quota. Zero means unlimited.
+type QuotaConfig struct {
+	DailyExecutions int64
+	ExecPriceBudget int64
+}
+
+// WatchdogConfig controls how often every contract chain is checked against
+// its signed head. A zero Interval disables the watchdog.
+type WatchdogConfig struct {
+	Interval time.Duration
 }
 
 type DBConfig struct {
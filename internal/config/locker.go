@@ -1,6 +1,11 @@
 package config
 
-import "sync"
+import (
+	"sync"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/metrics"
+)
 
 type ContractLocker struct {
 	locks map[string]*sync.Mutex
@@ -22,7 +27,9 @@ func (cl *ContractLocker) Lock(contractID string) {
 	}
 	cl.mu.Unlock()
 
+	start := time.Now()
 	lock.Lock()
+	metrics.ObserveLockWait(time.Since(start))
 }
 
 func (cl *ContractLocker) Unlock(contractID string) {
@@ -34,3 +41,11 @@ func (cl *ContractLocker) Unlock(contractID string) {
 		lock.Unlock()
 	}
 }
+
+// Len returns the number of contracts with a lock in the table.
+func (cl *ContractLocker) Len() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return len(cl.locks)
+}
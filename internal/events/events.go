@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type Type string
+
+const (
+	BlockCommitted   Type = "block.committed"
+	ContractDeployed Type = "contract.deployed"
+	WebhookTest      Type = "webhook.test"
+	ContractTampered Type = "contract.tampered"
+)
+
+type Event struct {
+	ID         string          `json:"id"`
+	Type       Type            `json:"type"`
+	ContractID string          `json:"contract_id"`
+	Function   string          `json:"function,omitempty"`
+	Timestamp  int64           `json:"timestamp"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type BlockData struct {
+	BlockIndex   int64  `json:"block_index"`
+	Hash         string `json:"hash"`
+	PreviousHash string `json:"previous_hash"`
+	JournalHash  string `json:"journal_hash"`
+	JournalRoot  string `json:"journal_root"`
+	ArgsHash     string `json:"args_hash"`
+	Signature    string `json:"signature"`
+	Timestamp    int64  `json:"timestamp"`
+	HashVersion  int    `json:"hash_version"`
+	ExecPrice    int64  `json:"exec_price"`
+}
+
+type DeployData struct {
+	ContractName    string `json:"contract_name"`
+	ContractOwner   string `json:"contract_owner"`
+	ContractVersion string `json:"contract_version"`
+	AgentHash       string `json:"agent_hash"`
+}
+
+// TamperData describes a contract chain that no longer matches its signed
+// head. The contract is frozen when the event is raised.
+type TamperData struct {
+	Reason      string `json:"reason"`
+	SignedIndex int64  `json:"signed_index"`
+	SignedHash  string `json:"signed_hash"`
+	StoredIndex int64  `json:"stored_index"`
+	StoredHash  string `json:"stored_hash"`
+	DetectedAt  int64  `json:"detected_at"`
+}
+
+// Publisher is anything that wants to hear about committed blocks and
+// deployed contracts.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Publishers fans an event out to every publisher in the list.
+type Publishers []Publisher
+
+func (p Publishers) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, pub := range p {
+		if err := pub.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func New(eventType Type, contractID string, function string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		ContractID: contractID,
+		Function:   function,
+		Timestamp:  time.Now().UTC().UnixMilli(),
+		Data:       raw,
+	}, nil
+}
+
+func NewBlockEvent(block *schema.Block) (Event, error) {
+	return New(BlockCommitted, block.ContractID, block.FunctionName, BlockData{
+		BlockIndex:   block.BlockIndex,
+		Hash:         block.Hash,
+		PreviousHash: block.PreviousHash,
+		JournalHash:  block.JournalHash,
+		JournalRoot:  block.JournalRoot,
+		ArgsHash:     block.ArgsHash,
+		Signature:    "0x" + hex.EncodeToString(block.Signature),
+		Timestamp:    block.Timestamp,
+		HashVersion:  block.HashVersion,
+		ExecPrice:    block.ExecPrice,
+	})
+}
@@ -0,0 +1,275 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/auth"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	maxBodySize  = 11 << 20
+
+	sweepInterval = 15 * time.Minute
+
+	// reservationLease is how long a reservation may stay in progress before
+	// a retry takes it over; it outlives any request the server lets run.
+	reservationLease = 5 * time.Minute
+
+	reserveAttempts = 3
+)
+
+// Middleware makes mutating routes safe to retry. The first request carrying
+// a given Idempotency-Key is executed and its response stored; repeats within
+// the retention window get the stored response back, and reusing a key for a
+// different request is rejected with a conflict. Failures a retry may get
+// past release the key instead of being stored. Expired keys are deleted by
+// Sweep.
+func Middleware(repo repository.IdempotencyRepository, retention time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxKeyLength {
+				apperr.Write(w, r, apperr.New(apperr.CodeValidation, "Idempotency-Key is too long"))
+				return
+			}
+
+			requestHash, err := fingerprint(w, r)
+			if err != nil {
+				apperr.Write(w, r, apperr.Wrap(apperr.CodeValidation, "failed to read request body", err))
+				return
+			}
+
+			ctx := r.Context()
+			identity, _ := ctx.Value(auth.ContextUserIDKey).(string)
+			now := time.Now().UTC()
+
+			record := &schema.IdempotencyRecord{
+				Identity:    identity,
+				Key:         key,
+				Route:       r.Method + " " + r.URL.Path,
+				RequestHash: requestHash,
+				CreatedAt:   now.UnixMilli(),
+			}
+
+			// A key deleted between a failed Reserve and reading it back was
+			// released or expired, so the request may claim it after all.
+			for attempt := 1; ; attempt++ {
+				reserved, err := repo.Reserve(ctx, record, now.Add(-retention).UnixMilli(), now.Add(-reservationLease).UnixMilli())
+				if err != nil {
+					apperr.Write(w, r, err)
+					return
+				}
+				if reserved {
+					break
+				}
+
+				existing, err := repo.Get(ctx, identity, key)
+				if errors.Is(err, sql.ErrNoRows) && attempt < reserveAttempts {
+					continue
+				}
+				if err != nil {
+					apperr.Write(w, r, err)
+					return
+				}
+
+				replay(w, r, existing, record)
+				return
+			}
+
+			// The outcome must be stored even if the client already went away.
+			storeCtx := context.WithoutCancel(ctx)
+			release := func() {
+				if err := repo.Release(storeCtx, record); err != nil {
+					logging.FromContext(ctx).Error("Failed to release idempotency key", "key", key, "error", err)
+				}
+			}
+
+			// A panic never reaches the code below, so the key is released
+			// before the panic carries on to the recoverer.
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if retryable(rec.status) {
+				release()
+				return
+			}
+
+			record.StatusCode = rec.status
+			record.ContentType = rec.Header().Get("Content-Type")
+			record.Response = rec.body.Bytes()
+			if err := repo.Complete(storeCtx, record); err != nil {
+				logging.FromContext(ctx).Error("Failed to store idempotent response", "key", key, "error", err)
+			}
+		})
+	}
+}
+
+// retryable reports whether a response depends on conditions that pass, such
+// as a lock or a rate limit. Those are not stored, so a retry with the same
+// key runs the request again instead of replaying the failure.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusLocked, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
+
+// Sweep deletes keys older than retention every sweepInterval until ctx is
+// done.
+func Sweep(ctx context.Context, repo repository.IdempotencyRepository, retention time.Duration) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := repo.DeleteExpired(ctx, time.Now().UTC().Add(-retention).UnixMilli()); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to purge expired idempotency keys", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, existing *schema.IdempotencyRecord, record *schema.IdempotencyRecord) {
+	if existing.RequestHash != record.RequestHash {
+		apperr.Write(w, r, apperr.New(apperr.CodeConflict, "Idempotency-Key was already used with a different request"))
+		return
+	}
+
+	if !existing.Completed {
+		apperr.Write(w, r, apperr.New(apperr.CodeConflict, "a request with this Idempotency-Key is still in progress"))
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Response)
+}
+
+// fingerprint hashes the method, path and body of the request, leaving the
+// body readable for the next handler. JSON bodies are canonicalized and
+// multipart bodies are hashed part by part, so a retry that only differs in
+// formatting or multipart boundary is still recognised as the same request.
+// Numbers keep their digits, so large integers that only differ beyond
+// float64 precision are different requests.
+func fingerprint(w http.ResponseWriter, r *http.Request) (string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json":
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err == nil && !dec.More() {
+			body, _ = json.Marshal(v)
+		}
+		h.Write(body)
+	case mediaType == "multipart/form-data":
+		parts, err := hashParts(body, params["boundary"])
+		if err != nil {
+			return "", err
+		}
+		for _, p := range parts {
+			fmt.Fprintln(h, p)
+		}
+	default:
+		h.Write(body)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashParts(body []byte, boundary string) ([]string, error) {
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+
+	var parts []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.New()
+		if _, err := io.Copy(sum, part); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part.FormName()+"="+hex.EncodeToString(sum.Sum(nil)))
+	}
+	sort.Strings(parts)
+
+	return parts, nil
+}
+
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
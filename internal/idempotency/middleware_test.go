@@ -0,0 +1,177 @@
+package idempotency
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testRequest(t testing.TB, method string, path string, contentType string, body string) (*http.Request, string) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	hash, err := fingerprint(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatalf("fingerprint: %v", err)
+	}
+
+	return r, hash
+}
+
+func multipartBody(t testing.TB, boundary string, fields [][2]string) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fields {
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return w.FormDataContentType(), buf.String()
+}
+
+func TestFingerprint(t *testing.T) {
+	const path = "/contracts/abc/execute"
+
+	type request struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+	}
+	post := func(contentType string, body string) request {
+		return request{http.MethodPost, path, contentType, body}
+	}
+
+	formA, bodyA := multipartBody(t, "boundary-a", [][2]string{{"source", "contract x {}"}, {"name", "x"}})
+	formB, bodyB := multipartBody(t, "boundary-b", [][2]string{{"name", "x"}, {"source", "contract x {}"}})
+	formC, bodyC := multipartBody(t, "boundary-a", [][2]string{{"source", "contract y {}"}, {"name", "x"}})
+
+	tests := []struct {
+		name string
+		a, b request
+		same bool
+	}{
+		{
+			name: "json formatting",
+			a:    post("application/json", `{"function":"transfer","args":{"amount":1}}`),
+			b:    post("application/json; charset=utf-8", "{\n  \"args\": { \"amount\": 1 },\n  \"function\": \"transfer\"\n}"),
+			same: true,
+		},
+		{
+			name: "json values",
+			a:    post("application/json", `{"function":"transfer","args":{"amount":1}}`),
+			b:    post("application/json", `{"function":"transfer","args":{"amount":2}}`),
+		},
+		{
+			// Both decode to the same float64.
+			name: "json large integers",
+			a:    post("application/json", `{"args":{"amount":9007199254740993}}`),
+			b:    post("application/json", `{"args":{"amount":9007199254740992}}`),
+		},
+		{
+			name: "json large integers formatting",
+			a:    post("application/json", `{"args":{"amount":9007199254740993}}`),
+			b:    post("application/json", `{ "args" : { "amount" : 9007199254740993 } }`),
+			same: true,
+		},
+		{
+			name: "json trailing data",
+			a:    post("application/json", `{"function":"transfer"}`),
+			b:    post("application/json", `{"function":"transfer"} {"function":"mint"}`),
+		},
+		{
+			name: "multipart boundary and order",
+			a:    post(formA, bodyA),
+			b:    post(formB, bodyB),
+			same: true,
+		},
+		{
+			name: "multipart values",
+			a:    post(formA, bodyA),
+			b:    post(formC, bodyC),
+		},
+		{
+			name: "raw body",
+			a:    post("text/plain", "a"),
+			b:    post("text/plain", "b"),
+		},
+		{
+			name: "path",
+			a:    post("application/json", `{}`),
+			b:    request{http.MethodPost, "/contracts/def/execute", "application/json", `{}`},
+		},
+		{
+			name: "method",
+			a:    post("application/json", `{}`),
+			b:    request{http.MethodPut, path, "application/json", `{}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, a := testRequest(t, tt.a.method, tt.a.path, tt.a.contentType, tt.a.body)
+			_, b := testRequest(t, tt.b.method, tt.b.path, tt.b.contentType, tt.b.body)
+
+			if same := a == b; same != tt.same {
+				t.Errorf("got same=%v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestFingerprintKeepsBody(t *testing.T) {
+	const body = `{"function":"transfer","args":{"amount":9007199254740993}}`
+
+	r, _ := testRequest(t, http.MethodPost, "/contracts/abc/execute", "application/json", body)
+
+	got, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Errorf("got body %q, want %q", got, body)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusCreated, false},
+		{http.StatusBadRequest, false},
+		{http.StatusNotFound, false},
+		{http.StatusUnprocessableEntity, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusConflict, true},
+		{http.StatusLocked, true},
+		{http.StatusTooEarly, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		if got := retryable(tt.status); got != tt.want {
+			t.Errorf("retryable(%d): got %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
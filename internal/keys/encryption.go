@@ -50,7 +50,9 @@ func EncryptJournal(plain []byte, key []byte) ([]byte, error) {
 }
 
 func DecryptJournal(ciphertext []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	aesKey := deriveAESKey(key)
+
+	block, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return nil, err
 	}
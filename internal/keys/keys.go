@@ -3,8 +3,11 @@ package keys
 import (
 	"crypto/ed25519"
 	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 )
 
 func LoadOrCreateKeys(path string) (ed25519.PublicKey, ed25519.PrivateKey, error) {
@@ -63,3 +66,42 @@ func loadKeyFromFile(path string) (ed25519.PublicKey, ed25519.PrivateKey, error)
 func saveKeyToFile(path string, priv ed25519.PrivateKey) error {
 	return os.WriteFile(path, priv, 0600)
 }
+
+// ParsePublicKey decodes a hex encoded ed25519 public key, with or without
+// the 0x prefix.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d", len(raw))
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
+
+// LoadPublicKey reads a public key from a file holding either a raw public
+// key, a raw private key as written by LoadOrCreateKeys, or a hex string.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// A hex public key is 64 characters, the same length as a raw private
+	// key, so try hex first.
+	if pub, err := ParsePublicKey(string(data)); err == nil {
+		return pub, nil
+	}
+
+	switch len(data) {
+	case ed25519.PublicKeySize:
+		return ed25519.PublicKey(data), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(data).Public().(ed25519.PublicKey), nil
+	}
+
+	return ParsePublicKey(string(data))
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config selects the log format ("text" or "json") and minimum level
+// ("debug", "info", "warn" or "error"). Empty values mean text and info.
+type Config struct {
+	Format string
+	Level  string
+}
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never written out.
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"jwt":           true,
+	"authorization": true,
+	"journal":       true,
+	"args":          true,
+	"secret":        true,
+	"password":      true,
+	"private_key":   true,
+}
+
+// Setup builds the process logger from cfg, installs it as the slog default
+// and returns it.
+func Setup(cfg Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	return logger, nil
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+type contextKey struct{}
+
+type completionKey struct{}
+
+// completion holds attributes for the completion line that are only known
+// to handlers further down, such as the authenticated user.
+type completion struct {
+	mu   sync.Mutex
+	args []any
+}
+
+// FromContext returns the request-scoped logger carried by ctx, or the
+// default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// With returns a context whose logger carries the given attributes in
+// addition to those already on the context's logger.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
+
+// AddCompletion adds attributes to the completion line that Middleware logs
+// for the request carrying ctx. Outside such a request it does nothing.
+func AddCompletion(ctx context.Context, args ...any) {
+	c, ok := ctx.Value(completionKey{}).(*completion)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.args = append(c.args, args...)
+}
+
+// Middleware gives every request a logger carrying its request ID and trace
+// ID, and logs the request once it completes, with any attributes added by
+// AddCompletion.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		args := []any{"request_id", middleware.GetReqID(r.Context())}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			args = append(args, "trace_id", sc.TraceID().String())
+		}
+		ctx := With(r.Context(), args...)
+
+		c := &completion{}
+		ctx = context.WithValue(ctx, completionKey{}, c)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		c.mu.Lock()
+		logger := FromContext(ctx).With(c.args...)
+		c.mu.Unlock()
+
+		logger.Info("Request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
@@ -0,0 +1,131 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Leaves and inner nodes are hashed with distinct prefixes (as in RFC 6962)
+// so an inner node can never be passed off as a leaf. When a level has an
+// odd number of nodes the last one is promoted unchanged to the next level.
+
+const (
+	Left  = "left"
+	Right = "right"
+)
+
+var ErrIndexOutOfRange = errors.New("leaf index out of range")
+
+// Step is one sibling on the path from a leaf to the root. Position tells on
+// which side of the running hash the sibling goes.
+type Step struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"`
+}
+
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root returns the Merkle root of the leaves. The root of an empty tree is
+// the SHA-256 of the empty string.
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+
+	level := hashLeaves(leaves)
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+
+	return level[0]
+}
+
+// Proof returns the inclusion proof of the leaf at index.
+func Proof(leaves [][]byte, index int) ([]Step, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, ErrIndexOutOfRange
+	}
+
+	steps := []Step{}
+	level := hashLeaves(leaves)
+	for len(level) > 1 {
+		switch {
+		case index%2 == 1:
+			steps = append(steps, Step{Hash: encode(level[index-1]), Position: Left})
+		case index+1 < len(level):
+			steps = append(steps, Step{Hash: encode(level[index+1]), Position: Right})
+		}
+
+		level = nextLevel(level)
+		index /= 2
+	}
+
+	return steps, nil
+}
+
+// Verify checks that leaf is included under root following proof.
+func Verify(root []byte, leaf []byte, proof []Step) bool {
+	h := LeafHash(leaf)
+	for _, step := range proof {
+		sibling, err := decode(step.Hash)
+		if err != nil {
+			return false
+		}
+
+		switch step.Position {
+		case Left:
+			h = nodeHash(sibling, h)
+		case Right:
+			h = nodeHash(h, sibling)
+		default:
+			return false
+		}
+	}
+
+	return bytes.Equal(h, root)
+}
+
+func hashLeaves(leaves [][]byte) [][]byte {
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = LeafHash(leaf)
+	}
+	return level
+}
+
+func nextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			next = append(next, nodeHash(level[i], level[i+1]))
+		} else {
+			next = append(next, level[i])
+		}
+	}
+	return next
+}
+
+func encode(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
@@ -0,0 +1,158 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = []byte(fmt.Sprintf(`{"op":"set","value":%d}`, i))
+	}
+	return leaves
+}
+
+func TestProofRoundTrip(t *testing.T) {
+	// Sizes of 2^n+1 leave a single node to be promoted up every level.
+	for _, n := range []int{1, 2, 3, 4, 5, 8, 9, 17} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			leaves := testLeaves(n)
+			root := Root(leaves)
+
+			for i, leaf := range leaves {
+				proof, err := Proof(leaves, i)
+				if err != nil {
+					t.Fatalf("Proof(%d): %v", i, err)
+				}
+				if !Verify(root, leaf, proof) {
+					t.Errorf("leaf %d did not verify against its proof %v", i, proof)
+				}
+			}
+		})
+	}
+}
+
+func TestRoot(t *testing.T) {
+	empty := sha256.Sum256(nil)
+	if got := Root(nil); !bytes.Equal(got, empty[:]) {
+		t.Errorf("empty tree: got %x, want %x", got, empty)
+	}
+
+	leaves := testLeaves(3)
+	if got, want := Root(leaves[:1]), LeafHash(leaves[0]); !bytes.Equal(got, want) {
+		t.Errorf("single leaf: got %x, want %x", got, want)
+	}
+
+	// The third leaf is promoted, so it is hashed in only at the top.
+	want := nodeHash(nodeHash(LeafHash(leaves[0]), LeafHash(leaves[1])), LeafHash(leaves[2]))
+	if got := Root(leaves); !bytes.Equal(got, want) {
+		t.Errorf("three leaves: got %x, want %x", got, want)
+	}
+
+	// A leaf holding the two child hashes of an inner node must not hash
+	// to that node.
+	inner := append(LeafHash(leaves[0]), LeafHash(leaves[1])...)
+	if bytes.Equal(LeafHash(inner), Root(leaves[:2])) {
+		t.Error("inner node and leaf hashes collide")
+	}
+}
+
+func TestProofRejectsTampering(t *testing.T) {
+	leaves := testLeaves(9)
+	root := Root(leaves)
+
+	tests := []struct {
+		name   string
+		leaf   []byte
+		root   []byte
+		mutate func(proof []Step) []Step
+	}{
+		{name: "other leaf", leaf: leaves[5]},
+		{name: "other root", root: Root(leaves[:8])},
+		{
+			name: "sibling hash",
+			mutate: func(proof []Step) []Step {
+				proof[0].Hash = encode(LeafHash([]byte("forged")))
+				return proof
+			},
+		},
+		{
+			name: "sibling position",
+			mutate: func(proof []Step) []Step {
+				if proof[0].Position == Left {
+					proof[0].Position = Right
+				} else {
+					proof[0].Position = Left
+				}
+				return proof
+			},
+		},
+		{
+			name: "unknown position",
+			mutate: func(proof []Step) []Step {
+				proof[0].Position = "up"
+				return proof
+			},
+		},
+		{
+			name: "malformed hash",
+			mutate: func(proof []Step) []Step {
+				proof[0].Hash = "0xnothex"
+				return proof
+			},
+		},
+		{
+			name: "dropped step",
+			mutate: func(proof []Step) []Step {
+				return proof[1:]
+			},
+		},
+		{
+			name: "extra step",
+			mutate: func(proof []Step) []Step {
+				return append(proof, Step{Hash: encode(root), Position: Right})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := Proof(leaves, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			leaf, r := leaves[4], root
+			if tt.leaf != nil {
+				leaf = tt.leaf
+			}
+			if tt.root != nil {
+				r = tt.root
+			}
+			if tt.mutate != nil {
+				proof = tt.mutate(proof)
+			}
+
+			if Verify(r, leaf, proof) {
+				t.Error("tampered proof verified")
+			}
+		})
+	}
+}
+
+func TestProofIndexOutOfRange(t *testing.T) {
+	leaves := testLeaves(3)
+
+	for _, index := range []int{-1, 3} {
+		if _, err := Proof(leaves, index); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Errorf("Proof(%d): got %v, want %v", index, err, ErrIndexOutOfRange)
+		}
+	}
+	if _, err := Proof(nil, 0); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("Proof on empty tree: got %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
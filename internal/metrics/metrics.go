@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eeapi_http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	swpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eeapi_swp_roundtrip_seconds",
+		Help:    "SWP round trip latency by message type and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"type", "outcome"})
+
+	swpReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eeapi_swp_reconnects_total",
+		Help: "SWP reconnect attempts by outcome.",
+	}, []string{"outcome"})
+
+	lockWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "eeapi_contract_lock_wait_seconds",
+		Help:    "Time spent waiting for a contract lock.",
+		Buckets: []float64{.0001, .001, .005, .01, .05, .1, .5, 1, 5, 10, 30},
+	})
+
+	blocksAppended = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eeapi_blocks_appended_total",
+		Help: "Blocks appended by contract.",
+	}, []string{"contract_id"})
+
+	execPrice = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eeapi_exec_price",
+		Help:    "Execution price reported by the VM, by function.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 12),
+	}, []string{"function"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eeapi_db_query_duration_seconds",
+		Help:    "Database latency by repository method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"repository", "method"})
+
+	tampered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eeapi_contract_tamper_detected_total",
+		Help: "Contracts frozen after their chain diverged from the signed head.",
+	}, []string{"contract_id"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records request latency labelled by the matched route pattern
+// rather than the raw path, so IDs do not blow up the label set.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+func ObserveSWP(msgType string, err error, d time.Duration) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	swpDuration.WithLabelValues(msgType, outcome).Observe(d.Seconds())
+}
+
+func SWPReconnect(err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	swpReconnects.WithLabelValues(outcome).Inc()
+}
+
+func ObserveLockWait(d time.Duration) {
+	lockWait.Observe(d.Seconds())
+}
+
+func BlockAppended(contractID string, function string, price int64) {
+	blocksAppended.WithLabelValues(contractID).Inc()
+	execPrice.WithLabelValues(function).Observe(float64(price))
+}
+
+// ObserveQuery times a repository method; call the returned func when the
+// method returns.
+func ObserveQuery(repository string, method string) func() {
+	start := time.Now()
+	return func() {
+		dbDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+func TamperDetected(contractID string) {
+	tampered.WithLabelValues(contractID).Inc()
+}
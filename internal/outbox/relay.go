@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/repository"
+)
+
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+	Lease     time.Duration
+	LogFile   string
+	Topic     string
+}
+
+// Relay delivers outbox entries to every sink, in insertion order per
+// contract: when an entry fails, the contract's later entries wait until it
+// is delivered. An entry is marked delivered only after all sinks accepted
+// it, so sinks may see an event more than once and must tolerate duplicates.
+type Relay struct {
+	repo  repository.OutboxRepository
+	sinks []events.Publisher
+	cfg   Config
+}
+
+func NewRelay(repo repository.OutboxRepository, cfg Config, sinks ...events.Publisher) *Relay {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 100
+	}
+
+	return &Relay{
+		repo:  repo,
+		sinks: sinks,
+		cfg:   cfg,
+	}
+}
+
+// Run polls the outbox until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		delivered, err := r.relayBatch(ctx)
+		if err != nil {
+			slog.Error("Outbox relay failed", "error", err)
+		}
+
+		// A full batch means there is probably more waiting.
+		if err == nil && delivered == r.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	entries, err := r.repo.Claim(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	// Contracts whose delivery failed in this batch; their later entries are
+	// released so they go out after the failed one.
+	failed := make(map[string]bool)
+
+	for _, entry := range entries {
+		if failed[entry.ContractID] {
+			if err := r.repo.Release(ctx, entry.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		var event events.Event
+		if err := json.Unmarshal(entry.Payload, &event); err != nil {
+			slog.Error("Dropping malformed outbox entry", "outbox_id", entry.ID, "error", err)
+			r.repo.MarkDelivered(ctx, entry.ID)
+			continue
+		}
+
+		if err := events.Publishers(r.sinks).Publish(ctx, event); err != nil {
+			retryAfter := backoff(entry.Attempts)
+			slog.Warn("Outbox delivery failed", "outbox_id", entry.ID, "event_id", event.ID, "attempts", entry.Attempts, "retry_after", retryAfter, "error", err)
+			if err := r.repo.MarkFailed(ctx, entry.ID, err.Error(), retryAfter); err != nil {
+				return 0, err
+			}
+			failed[entry.ContractID] = true
+			continue
+		}
+
+		if err := r.repo.MarkDelivered(ctx, entry.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+func backoff(attempts int) time.Duration {
+	d := time.Second << min(attempts, 9)
+	return min(d, 5*time.Minute)
+}
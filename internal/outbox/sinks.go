@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/peiblow/eeapi/internal/events"
+)
+
+// LogFileSink appends each event as a JSON line to a file.
+type LogFileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewLogFileSink(path string) (*LogFileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogFileSink{file: file}, nil
+}
+
+func (s *LogFileSink) Publish(ctx context.Context, event events.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+func (s *LogFileSink) Close() error {
+	return s.file.Close()
+}
+
+// Broker is the subset of a NATS or Kafka producer the relay needs. Key is
+// the contract ID, so brokers that partition by key keep each contract's
+// events in order.
+type Broker interface {
+	Publish(ctx context.Context, topic string, key string, value []byte) error
+}
+
+type BrokerSink struct {
+	broker Broker
+	topic  string
+}
+
+func NewBrokerSink(broker Broker, topic string) *BrokerSink {
+	return &BrokerSink{broker: broker, topic: topic}
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, event events.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.broker.Publish(ctx, s.topic, event.ContractID, value)
+}
+
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+const memoryBrokerRetention = 1000
+
+// MemoryBroker is an in-process Broker for local runs and development. It
+// keeps the most recent messages and hands them to subscribers of the topic.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	messages []Message
+	subs     map[string][]chan Message
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		subs: make(map[string][]chan Message),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, key string, value []byte) error {
+	msg := Message{Topic: topic, Key: key, Value: value}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.messages = append(b.messages, msg)
+	if len(b.messages) > memoryBrokerRetention {
+		b.messages = b.messages[len(b.messages)-memoryBrokerRetention:]
+	}
+	for _, ch := range b.subs[topic] {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(topic string, buffer int) <-chan Message {
+	ch := make(chan Message, buffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs[topic] = append(b.subs[topic], ch)
+
+	return ch
+}
+
+func (b *MemoryBroker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message(nil), b.messages...)
+}
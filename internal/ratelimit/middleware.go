@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/auth"
+)
+
+// Middleware applies the rule configured for route. Requests are limited per
+// authenticated identity and, on routes with an {id} parameter, per contract;
+// the RateLimit-* headers describe whichever bucket is closer to empty.
+func (l *Limiter) Middleware(route string) func(http.Handler) http.Handler {
+	rule := l.cfg.Rule(route)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var decisions []Decision
+
+			if !rule.Identity.unlimited() {
+				key := route + "|identity|" + auth.Identity(r.Context())
+				decisions = append(decisions, l.Allow(key, rule.Identity))
+			}
+
+			if contractID := chi.URLParam(r, "id"); contractID != "" && !rule.Contract.unlimited() {
+				key := route + "|contract|" + contractID
+				decisions = append(decisions, l.Allow(key, rule.Contract))
+			}
+
+			if len(decisions) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d := tightest(decisions)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(d.Reset.Seconds())))
+
+			if !d.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(d.RetryAfter.Seconds())))
+				apperr.Write(w, r, apperr.New(apperr.CodeRateLimited, "rate limit exceeded, retry after "+d.RetryAfter.String()))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tightest picks a denying decision if there is one, otherwise the one with
+// the fewest tokens left.
+func tightest(decisions []Decision) Decision {
+	d := decisions[0]
+	for _, other := range decisions[1:] {
+		switch {
+		case d.Allowed && !other.Allowed:
+			d = other
+		case d.Allowed == other.Allowed && other.Remaining < d.Remaining:
+			d = other
+		}
+	}
+	return d
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Rate tokens are added per second up to Burst.
+// A zero Limit does not limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Rule limits a route per authenticated identity and per contract.
+type Rule struct {
+	Identity Limit
+	Contract Limit
+}
+
+// Config holds a rule per route name; routes without one use Default.
+type Config struct {
+	Default Rule
+	Routes  map[string]Rule
+}
+
+func (c Config) Rule(route string) Rule {
+	if rule, ok := c.Routes[route]; ok {
+		return rule
+	}
+	return c.Default
+}
+
+// Decision is the outcome of taking a token from a bucket.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available; zero if allowed.
+	RetryAfter time.Duration
+}
+
+const (
+	sweepInterval = time.Minute
+	sweepMinSize  = 1024
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// Limiter keeps the buckets in memory, so limits apply per replica.
+type Limiter struct {
+	cfg Config
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket stored under key.
+func (l *Limiter) Allow(key string, limit Limit) Decision {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	d := Decision{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return d
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.last = now
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would be identical. It must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if len(l.buckets) < sweepMinSize || now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// seconds rounds a number of seconds up to a whole second.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// backdate moves a bucket's last refill back by d, as if d had passed.
+func backdate(l *Limiter, key string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets[key].last = l.buckets[key].last.Add(-d)
+}
+
+func TestAllowBurstThenDeny(t *testing.T) {
+	l := New(Config{})
+	limit := Limit{Rate: 0.5, Burst: 3}
+
+	for i := range limit.Burst {
+		d := l.Allow("key", limit)
+		if !d.Allowed {
+			t.Fatalf("request %d denied within the burst", i+1)
+		}
+		if want := limit.Burst - i - 1; d.Remaining != want {
+			t.Errorf("request %d: got remaining %d, want %d", i+1, d.Remaining, want)
+		}
+		if d.Limit != limit.Burst {
+			t.Errorf("request %d: got limit %d, want %d", i+1, d.Limit, limit.Burst)
+		}
+	}
+
+	d := l.Allow("key", limit)
+	if d.Allowed {
+		t.Fatal("request past the burst allowed")
+	}
+	if d.Remaining != 0 {
+		t.Errorf("got remaining %d, want 0", d.Remaining)
+	}
+	// One token takes two seconds at half a token per second.
+	if d.RetryAfter != 2*time.Second {
+		t.Errorf("got retry after %v, want 2s", d.RetryAfter)
+	}
+	if d.Reset != 6*time.Second {
+		t.Errorf("got reset %v, want 6s", d.Reset)
+	}
+}
+
+func TestAllowRefills(t *testing.T) {
+	l := New(Config{})
+	limit := Limit{Rate: 1, Burst: 2}
+
+	l.Allow("key", limit)
+	l.Allow("key", limit)
+	if l.Allow("key", limit).Allowed {
+		t.Fatal("empty bucket allowed a request")
+	}
+
+	backdate(l, "key", time.Second)
+	if !l.Allow("key", limit).Allowed {
+		t.Fatal("request denied after a token was added")
+	}
+	if l.Allow("key", limit).Allowed {
+		t.Fatal("refill added more than one token")
+	}
+
+	// Refilling stops at the burst.
+	backdate(l, "key", time.Hour)
+	for i := range limit.Burst {
+		if !l.Allow("key", limit).Allowed {
+			t.Fatalf("request %d denied after a full refill", i+1)
+		}
+	}
+	if l.Allow("key", limit).Allowed {
+		t.Fatal("bucket refilled past its burst")
+	}
+}
+
+func TestAllowKeysAreIndependent(t *testing.T) {
+	l := New(Config{})
+	limit := Limit{Rate: 1, Burst: 1}
+
+	if !l.Allow("a", limit).Allowed {
+		t.Fatal("first request for a denied")
+	}
+	if l.Allow("a", limit).Allowed {
+		t.Fatal("second request for a allowed")
+	}
+	if !l.Allow("b", limit).Allowed {
+		t.Fatal("b was limited by a's bucket")
+	}
+}
+
+func TestAllowLimitChangeResetsBucket(t *testing.T) {
+	l := New(Config{})
+
+	l.Allow("key", Limit{Rate: 1, Burst: 1})
+	if !l.Allow("key", Limit{Rate: 1, Burst: 5}).Allowed {
+		t.Fatal("bucket kept the old limit")
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	l := New(Config{})
+	limit := Limit{Rate: 1, Burst: 1}
+
+	for i := range sweepMinSize {
+		l.Allow(fmt.Sprint(i), limit)
+	}
+	backdate(l, "0", time.Second)
+
+	l.mu.Lock()
+	l.sweep(time.Now())
+	remaining := len(l.buckets)
+	_, kept := l.buckets["0"]
+	l.mu.Unlock()
+
+	if remaining != sweepMinSize-1 || kept {
+		t.Errorf("got %d buckets (refilled bucket kept: %v), want %d", remaining, kept, sweepMinSize-1)
+	}
+}
+
+func TestTightest(t *testing.T) {
+	tests := []struct {
+		name      string
+		decisions []Decision
+		want      Decision
+	}{
+		{
+			name:      "fewest remaining",
+			decisions: []Decision{{Allowed: true, Remaining: 5}, {Allowed: true, Remaining: 2}},
+			want:      Decision{Allowed: true, Remaining: 2},
+		},
+		{
+			name:      "denial wins",
+			decisions: []Decision{{Allowed: true, Remaining: 0}, {Allowed: false, Remaining: 0, RetryAfter: time.Second}},
+			want:      Decision{Allowed: false, Remaining: 0, RetryAfter: time.Second},
+		},
+		{
+			name:      "first denial kept",
+			decisions: []Decision{{Allowed: false, RetryAfter: time.Second}, {Allowed: true, Remaining: 3}},
+			want:      Decision{Allowed: false, RetryAfter: time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tightest(tt.decisions); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := New(Config{
+		Default: Rule{Identity: Limit{Rate: 1, Burst: 10}},
+		Routes: map[string]Rule{
+			"execute": {
+				Identity: Limit{Rate: 1, Burst: 10},
+				Contract: Limit{Rate: 0.5, Burst: 2},
+			},
+		},
+	})
+
+	r := chi.NewRouter()
+	r.With(l.Middleware("execute")).Post("/contracts/{id}/execute", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	do := func(contractID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/contracts/"+contractID+"/execute", nil))
+		return rec
+	}
+
+	for i := range 2 {
+		rec := do("abc")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: got status %d, want %d", i+1, rec.Code, http.StatusNoContent)
+		}
+		// The contract bucket is the tighter one.
+		if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: got RateLimit-Limit %s, want 2", i+1, got)
+		}
+	}
+
+	rec := do("abc")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("got Retry-After %s, want 2", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("got RateLimit-Remaining %s, want 0", got)
+	}
+
+	if rec := do("def"); rec.Code != http.StatusNoContent {
+		t.Errorf("other contract: got status %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,158 @@
+package receipt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+const Version = 1
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported receipt version")
+	ErrPublicKeyMismatch  = errors.New("receipt was issued by a different node key")
+	ErrBlockSignature     = errors.New("invalid block signature")
+	ErrReceiptSignature   = errors.New("invalid receipt signature")
+)
+
+// Receipt is a portable proof that an execution was recorded in a block.
+// It carries everything needed to check it offline against the node's
+// public key.
+type Receipt struct {
+	Version        int    `json:"version"`
+	ContractID     string `json:"contract_id"`
+	Function       string `json:"function"`
+	ArgsHash       string `json:"args_hash"`
+	JournalHash    string `json:"journal_hash"`
+	BlockHash      string `json:"block_hash"`
+	BlockIndex     int64  `json:"block_index"`
+	Timestamp      int64  `json:"timestamp"`
+	BlockSignature string `json:"block_signature"`
+	PublicKey      string `json:"public_key"`
+	Signature      string `json:"signature"`
+}
+
+func New(block *schema.Block, priv ed25519.PrivateKey) *Receipt {
+	r := &Receipt{
+		Version:        Version,
+		ContractID:     block.ContractID,
+		Function:       block.FunctionName,
+		ArgsHash:       block.ArgsHash,
+		JournalHash:    block.JournalHash,
+		BlockHash:      block.Hash,
+		BlockIndex:     block.BlockIndex,
+		Timestamp:      block.Timestamp,
+		BlockSignature: encodeHex(block.Signature),
+		PublicKey:      encodeHex(priv.Public().(ed25519.PublicKey)),
+	}
+	r.Signature = encodeHex(ed25519.Sign(priv, r.SigningBytes()))
+
+	return r
+}
+
+// SigningBytes returns the bytes covered by the receipt signature: a fixed
+// domain tag followed by every field except the signature, strings as a
+// big-endian uint32 length plus UTF-8 bytes and integers as big-endian int64.
+func (r *Receipt) SigningBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("eeapi-receipt")
+	writeInt(&buf, int64(r.Version))
+	writeString(&buf, r.ContractID)
+	writeString(&buf, r.Function)
+	writeString(&buf, r.ArgsHash)
+	writeString(&buf, r.JournalHash)
+	writeString(&buf, r.BlockHash)
+	writeInt(&buf, r.BlockIndex)
+	writeInt(&buf, r.Timestamp)
+	writeString(&buf, r.BlockSignature)
+	writeString(&buf, r.PublicKey)
+
+	return buf.Bytes()
+}
+
+// Verify checks both the node's signature over the block hash and its
+// signature over the receipt itself.
+func (r *Receipt) Verify(pub ed25519.PublicKey) error {
+	if r.Version != Version {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, r.Version)
+	}
+
+	if r.PublicKey != "" && !strings.EqualFold(r.PublicKey, encodeHex(pub)) {
+		return ErrPublicKeyMismatch
+	}
+
+	blockHash, err := decodeHex(r.BlockHash)
+	if err != nil {
+		return fmt.Errorf("invalid block hash: %w", err)
+	}
+
+	blockSig, err := decodeHex(r.BlockSignature)
+	if err != nil {
+		return fmt.Errorf("invalid block signature encoding: %w", err)
+	}
+
+	if !ed25519.Verify(pub, blockHash, blockSig) {
+		return ErrBlockSignature
+	}
+
+	sig, err := decodeHex(r.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid receipt signature encoding: %w", err)
+	}
+
+	if !ed25519.Verify(pub, r.SigningBytes(), sig) {
+		return ErrReceiptSignature
+	}
+
+	return nil
+}
+
+func (r *Receipt) MarshalCBOR() ([]byte, error) {
+	type plain Receipt
+	return cbor.Marshal((*plain)(r))
+}
+
+// Parse decodes a receipt from either its JSON or CBOR encoding.
+func Parse(data []byte) (*Receipt, error) {
+	var r Receipt
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &r); err != nil {
+			return nil, err
+		}
+		return &r, nil
+	}
+
+	type plain Receipt
+	if err := cbor.Unmarshal(data, (*plain)(&r)); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func writeInt(buf *bytes.Buffer, v int64) {
+	binary.Write(buf, binary.BigEndian, v)
+}
+
+func encodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
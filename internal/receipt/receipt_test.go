@@ -0,0 +1,138 @@
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+var testKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+// testBlock returns a block whose hash is signed with testKey.
+func testBlock() *schema.Block {
+	hash := sha256.Sum256([]byte("block"))
+
+	return &schema.Block{
+		BlockIndex:   7,
+		Hash:         "0x" + hex.EncodeToString(hash[:]),
+		Timestamp:    1700000000000,
+		PreviousHash: "0xprevious",
+		JournalHash:  "0xjournal",
+		ContractID:   "contract",
+		FunctionName: "transfer",
+		ArgsHash:     "0xargs",
+		Signature:    ed25519.Sign(testKey, hash[:]),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	pub := testKey.Public().(ed25519.PublicKey)
+	r := New(testBlock(), testKey)
+
+	if err := r.Verify(pub); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	asJSON, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asCBOR, err := r.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"json": asJSON, "cbor": asCBOR} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if *parsed != *r {
+				t.Errorf("got %+v, want %+v", parsed, r)
+			}
+			if err := parsed.Verify(pub); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	other := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1))
+
+	tests := []struct {
+		name   string
+		key    ed25519.PublicKey
+		mutate func(r *Receipt)
+		want   error
+	}{
+		{name: "version", mutate: func(r *Receipt) { r.Version = 2 }, want: ErrUnsupportedVersion},
+		{name: "other key", key: other.Public().(ed25519.PublicKey), want: ErrPublicKeyMismatch},
+		{name: "contract", mutate: func(r *Receipt) { r.ContractID = "other" }, want: ErrReceiptSignature},
+		{name: "function", mutate: func(r *Receipt) { r.Function = "mint" }, want: ErrReceiptSignature},
+		{name: "args hash", mutate: func(r *Receipt) { r.ArgsHash = "0x00" }, want: ErrReceiptSignature},
+		{name: "journal hash", mutate: func(r *Receipt) { r.JournalHash = "0x00" }, want: ErrReceiptSignature},
+		{name: "block index", mutate: func(r *Receipt) { r.BlockIndex++ }, want: ErrReceiptSignature},
+		{name: "timestamp", mutate: func(r *Receipt) { r.Timestamp++ }, want: ErrReceiptSignature},
+		{name: "receipt signature", mutate: func(r *Receipt) { r.Signature = r.BlockSignature }, want: ErrReceiptSignature},
+		{
+			name: "block hash",
+			mutate: func(r *Receipt) {
+				hash := sha256.Sum256([]byte("other block"))
+				r.BlockHash = "0x" + hex.EncodeToString(hash[:])
+			},
+			want: ErrBlockSignature,
+		},
+		{
+			// Re-signing the receipt over a forged block signature must
+			// still fail on the block signature.
+			name: "block signature",
+			mutate: func(r *Receipt) {
+				r.BlockSignature = encodeHex(ed25519.Sign(other, []byte("forged")))
+				r.Signature = encodeHex(ed25519.Sign(testKey, r.SigningBytes()))
+			},
+			want: ErrBlockSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(testBlock(), testKey)
+			if tt.mutate != nil {
+				tt.mutate(r)
+			}
+
+			key := testKey.Public().(ed25519.PublicKey)
+			if tt.key != nil {
+				key = tt.key
+			}
+
+			if err := r.Verify(key); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformedHex(t *testing.T) {
+	pub := testKey.Public().(ed25519.PublicKey)
+
+	for _, mutate := range []func(r *Receipt){
+		func(r *Receipt) { r.BlockHash = "0xnothex" },
+		func(r *Receipt) { r.BlockSignature = "0xnothex" },
+		func(r *Receipt) { r.Signature = "0xnothex" },
+	} {
+		r := New(testBlock(), testKey)
+		mutate(r)
+
+		if err := r.Verify(pub); err == nil {
+			t.Errorf("receipt %+v verified", r)
+		}
+	}
+}
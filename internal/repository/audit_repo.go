@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/peiblow/eeapi/internal/audit"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// auditLockKey serialises appends so each event links to the one before it.
+const auditLockKey = 0x61756469
+
+type AuditRepository interface {
+	Append(ctx context.Context, event *schema.AuditEvent) error
+	ListEvents(ctx context.Context, filter schema.AuditFilter) ([]*schema.AuditEvent, error)
+}
+
+type PsqlAuditRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlAuditRepository(db *postgres.DB) AuditRepository {
+	return &PsqlAuditRepository{db: db}
+}
+
+const auditColumns = `seq, request_id, actor, method, route, path, contract_id, function_name, status, outcome, remote_addr, created_at, previous_hash, hash`
+
+// Append links the event to the current end of the chain and stores it.
+func (r *PsqlAuditRepository) Append(ctx context.Context, event *schema.AuditEvent) error {
+	defer observe(ctx, "audit", "Append")()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return err
+	}
+
+	event.Seq, event.PreviousHash = 1, audit.GenesisHash
+	var lastSeq int64
+	var lastHash string
+	err = tx.QueryRowContext(ctx, `SELECT seq, hash FROM audit_events ORDER BY seq DESC LIMIT 1`).Scan(&lastSeq, &lastHash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return err
+	default:
+		event.Seq, event.PreviousHash = lastSeq+1, lastHash
+	}
+	event.Hash = audit.Hash(event)
+
+	query := `
+		INSERT INTO audit_events (` + auditColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		event.Seq,
+		event.RequestID,
+		event.Actor,
+		event.Method,
+		event.Route,
+		event.Path,
+		event.ContractID,
+		event.Function,
+		event.Status,
+		event.Outcome,
+		event.RemoteAddr,
+		event.CreatedAt,
+		event.PreviousHash,
+		event.Hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PsqlAuditRepository) ListEvents(ctx context.Context, filter schema.AuditFilter) ([]*schema.AuditEvent, error) {
+	defer observe(ctx, "audit", "ListEvents")()
+
+	conditions := []string{"seq > $1"}
+	args := []any{filter.AfterSeq}
+	where := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Actor != "" {
+		where("actor = $%d", filter.Actor)
+	}
+	if filter.ContractID != "" {
+		where("contract_id = $%d", filter.ContractID)
+	}
+	if filter.Outcome != "" {
+		where("outcome = $%d", filter.Outcome)
+	}
+	if filter.From > 0 {
+		where("created_at >= $%d", filter.From)
+	}
+	if filter.To > 0 {
+		where("created_at < $%d", filter.To)
+	}
+	args = append(args, filter.Limit)
+
+	query := `SELECT ` + auditColumns + ` FROM audit_events WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(` ORDER BY seq ASC LIMIT $%d`, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*schema.AuditEvent
+	for rows.Next() {
+		var e schema.AuditEvent
+		err := rows.Scan(
+			&e.Seq,
+			&e.RequestID,
+			&e.Actor,
+			&e.Method,
+			&e.Route,
+			&e.Path,
+			&e.ContractID,
+			&e.Function,
+			&e.Status,
+			&e.Outcome,
+			&e.RemoteAddr,
+			&e.CreatedAt,
+			&e.PreviousHash,
+			&e.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+
+	return events, rows.Err()
+}
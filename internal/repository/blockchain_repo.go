@@ -4,17 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log/slog"
 	"time"
 
+	"github.com/peiblow/eeapi/internal/blocks"
 	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/logging"
 	"github.com/peiblow/eeapi/internal/schema"
 )
 
 type BlockRepository interface {
-	SaveBlock(ctx context.Context, block *schema.Block) error
+	SaveBlock(ctx context.Context, block *schema.Block, head *schema.ContractHead, usage *schema.UsageEntry, event events.Event) error
 	GetBlockByID(ctx context.Context, id string) (*schema.Block, error)
+	GetBlockByHash(ctx context.Context, hash string) (*schema.Block, error)
+	GetContractBlock(ctx context.Context, contractId string, index int64) (*schema.Block, error)
 	GetLastContractBlock(ctx context.Context, contractId string) (*schema.Block, error)
+	ListContractBlocks(ctx context.Context, contractId string, afterIndex int64, limit int) ([]*schema.Block, error)
 }
 
 type PsqlBlockRepository struct {
@@ -25,12 +30,79 @@ func NewPsqlBlockRepository(db *postgres.DB) BlockRepository {
 	return &PsqlBlockRepository{db: db}
 }
 
-func (r *PsqlBlockRepository) SaveBlock(ctx context.Context, block *schema.Block) error {
+const blockColumns = `block_index, hash, timestamp, previous_hash, journal_hash, signature, contract_id, function_name, journal, args_hash, journal_root, artifact_hash, hash_version, exec_price`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBlock(row rowScanner) (*schema.Block, error) {
+	var block schema.Block
+	err := row.Scan(
+		&block.BlockIndex,
+		&block.Hash,
+		&block.Timestamp,
+		&block.PreviousHash,
+		&block.JournalHash,
+		&block.Signature,
+		&block.ContractID,
+		&block.FunctionName,
+		&block.Journal,
+		&block.ArgsHash,
+		&block.JournalRoot,
+		&block.ArtifactHash,
+		&block.HashVersion,
+		&block.ExecPrice,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// SaveBlock inserts the block, moves the signed contract head, records the
+// usage entry, charges the caller's budget and writes the outbox event in one
+// transaction, so the event is published, the head moves and the execution
+// is billed if and only if the block is committed.
+func (r *PsqlBlockRepository) SaveBlock(ctx context.Context, block *schema.Block, head *schema.ContractHead, usage *schema.UsageEntry, event events.Event) error {
+	defer observe(ctx, "blocks", "SaveBlock")()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertBlock(ctx, tx, block); err != nil {
+		return err
+	}
+
+	if err := upsertHead(ctx, tx, head); err != nil {
+		return err
+	}
+
+	if err := insertUsage(ctx, tx, usage); err != nil {
+		return err
+	}
+
+	if err := addSpend(ctx, tx, usage.Caller, usage.ExecPrice); err != nil {
+		return err
+	}
+
+	if err := insertOutbox(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertBlock(ctx context.Context, db execer, block *schema.Block) error {
 	query := `
-		INSERT INTO blocks (block_index, hash, timestamp, previous_hash, journal_hash, signature, contract_id, function_name, journal)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO blocks (` + blockColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := db.ExecContext(ctx, query,
 		block.BlockIndex,
 		block.Hash,
 		block.Timestamp,
@@ -40,95 +112,95 @@ func (r *PsqlBlockRepository) SaveBlock(ctx context.Context, block *schema.Block
 		block.ContractID,
 		block.FunctionName,
 		block.Journal,
+		block.ArgsHash,
+		block.JournalRoot,
+		block.ArtifactHash,
+		block.HashVersion,
+		block.ExecPrice,
 	)
 
 	return err
 }
 
 func (r *PsqlBlockRepository) GetBlockByID(ctx context.Context, id string) (*schema.Block, error) {
-	query := `SELECT hash, timestamp, previous_hash, journal_hash, signature, contract_id, function_name, journal FROM blocks WHERE id = $1`
+	defer observe(ctx, "blocks", "GetBlockByID")()
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	query := `SELECT ` + blockColumns + ` FROM blocks WHERE id = $1`
 
-	var block schema.Block
-	err := row.Scan(
-		&block.Hash,
-		&block.Timestamp,
-		&block.PreviousHash,
-		&block.JournalHash,
-		&block.Signature,
-		&block.ContractID,
-		&block.FunctionName,
-		&block.Journal,
-	)
-	if err != nil {
-		return nil, err
-	}
+	return scanBlock(r.db.QueryRowContext(ctx, query, id))
+}
 
-	return &block, nil
+func (r *PsqlBlockRepository) GetBlockByHash(ctx context.Context, hash string) (*schema.Block, error) {
+	defer observe(ctx, "blocks", "GetBlockByHash")()
+
+	query := `SELECT ` + blockColumns + ` FROM blocks WHERE hash = $1`
+
+	return scanBlock(r.db.QueryRowContext(ctx, query, hash))
+}
+
+func (r *PsqlBlockRepository) GetContractBlock(ctx context.Context, contractId string, index int64) (*schema.Block, error) {
+	defer observe(ctx, "blocks", "GetContractBlock")()
+
+	query := `SELECT ` + blockColumns + ` FROM blocks WHERE contract_id = $1 AND block_index = $2`
+
+	return scanBlock(r.db.QueryRowContext(ctx, query, contractId, index))
 }
 
 func (r *PsqlBlockRepository) GetLastContractBlock(ctx context.Context, contractId string) (*schema.Block, error) {
-	query := `SELECT block_index, hash, timestamp, previous_hash, journal_hash, signature, contract_id, function_name, journal FROM blocks WHERE contract_id = $1 ORDER BY timestamp DESC LIMIT 1`
-	row := r.db.QueryRowContext(ctx, query, contractId)
+	defer observe(ctx, "blocks", "GetLastContractBlock")()
 
-	var block schema.Block
-	err := row.Scan(
-		&block.BlockIndex,
-		&block.Hash,
-		&block.Timestamp,
-		&block.PreviousHash,
-		&block.JournalHash,
-		&block.Signature,
-		&block.ContractID,
-		&block.FunctionName,
-		&block.Journal,
-	)
+	query := `SELECT ` + blockColumns + ` FROM blocks WHERE contract_id = $1 ORDER BY timestamp DESC LIMIT 1`
 
+	block, err := scanBlock(r.db.QueryRowContext(ctx, query, contractId))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			slog.Info("No blocks found in database, creating genesis block")
+			logging.FromContext(ctx).Info("No blocks found in database, creating genesis block", "contract_id", contractId)
 			return r.createGenesisBlock(ctx, contractId)
 		}
 		return nil, err
 	}
 
-	return &block, nil
+	return block, nil
+}
+
+func (r *PsqlBlockRepository) ListContractBlocks(ctx context.Context, contractId string, afterIndex int64, limit int) ([]*schema.Block, error) {
+	defer observe(ctx, "blocks", "ListContractBlocks")()
+
+	query := `SELECT ` + blockColumns + ` FROM blocks WHERE contract_id = $1 AND block_index > $2 ORDER BY block_index ASC LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, query, contractId, afterIndex, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []*schema.Block
+	for rows.Next() {
+		block, err := scanBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
 }
 
 func (r *PsqlBlockRepository) createGenesisBlock(ctx context.Context, contractId string) (*schema.Block, error) {
-	slog.Info("No blocks found in database, creating genesis block")
+	logging.FromContext(ctx).Info("No blocks found in database, creating genesis block", "contract_id", contractId)
 	genesis := &schema.Block{
 		BlockIndex:   1,
-		Hash:         "0xGENESIS_HASH",
+		Hash:         blocks.GenesisHash,
 		Timestamp:    time.Now().Unix(),
 		PreviousHash: "0",
 		JournalHash:  "0",
-		Signature:    []byte("GENESIS_SIGNATURE"),
+		Signature:    []byte(blocks.GenesisSignature),
 		ContractID:   contractId,
 		FunctionName: "genesis",
+		HashVersion:  1,
 		Journal:      []byte{},
 	}
 
-	query := `
-		INSERT INTO blocks (
-			block_index, hash, timestamp, previous_hash, journal_hash, signature, contract_id, function_name, journal
-		)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
-	`
-
-	_, err := r.db.ExecContext(ctx, query,
-		genesis.BlockIndex,
-		genesis.Hash,
-		genesis.Timestamp,
-		genesis.PreviousHash,
-		genesis.JournalHash,
-		genesis.Signature,
-		genesis.ContractID,
-		genesis.FunctionName,
-		genesis.Journal,
-	)
-	if err != nil {
+	if err := insertBlock(ctx, r.db, genesis); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type CheckpointRepository interface {
+	ListContractHeads(ctx context.Context) ([]schema.CheckpointHead, error)
+	SaveCheckpoint(ctx context.Context, checkpoint *schema.Checkpoint) error
+	GetLatestCheckpoint(ctx context.Context) (*schema.Checkpoint, error)
+	GetCheckpoint(ctx context.Context, seq int64) (*schema.Checkpoint, error)
+	FindCoveringCheckpoint(ctx context.Context, contractID string, blockIndex int64) (*schema.Checkpoint, error)
+}
+
+type PsqlCheckpointRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlCheckpointRepository(db *postgres.DB) CheckpointRepository {
+	return &PsqlCheckpointRepository{db: db}
+}
+
+// ListContractHeads returns the last block of every contract chain, ordered
+// by contract ID.
+func (r *PsqlCheckpointRepository) ListContractHeads(ctx context.Context) ([]schema.CheckpointHead, error) {
+	defer observe(ctx, "checkpoints", "ListContractHeads")()
+
+	query := `
+		SELECT DISTINCT ON (contract_id) contract_id, block_index, hash
+		FROM blocks
+		ORDER BY contract_id ASC, block_index DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var heads []schema.CheckpointHead
+	for rows.Next() {
+		head := schema.CheckpointHead{Position: len(heads)}
+		if err := rows.Scan(&head.ContractID, &head.BlockIndex, &head.BlockHash); err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+
+	return heads, rows.Err()
+}
+
+func (r *PsqlCheckpointRepository) SaveCheckpoint(ctx context.Context, checkpoint *schema.Checkpoint) error {
+	defer observe(ctx, "checkpoints", "SaveCheckpoint")()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO checkpoints (seq, root, previous_hash, hash, signature, head_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		checkpoint.Seq,
+		checkpoint.Root,
+		checkpoint.PreviousHash,
+		checkpoint.Hash,
+		checkpoint.Signature,
+		checkpoint.HeadCount,
+		checkpoint.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	headQuery := `
+		INSERT INTO checkpoint_heads (checkpoint_seq, position, contract_id, block_index, block_hash)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	for _, head := range checkpoint.Heads {
+		if _, err := tx.ExecContext(ctx, headQuery, checkpoint.Seq, head.Position, head.ContractID, head.BlockIndex, head.BlockHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLatestCheckpoint returns nil when no checkpoint was taken yet.
+func (r *PsqlCheckpointRepository) GetLatestCheckpoint(ctx context.Context) (*schema.Checkpoint, error) {
+	defer observe(ctx, "checkpoints", "GetLatestCheckpoint")()
+
+	query := `SELECT seq FROM checkpoints ORDER BY seq DESC LIMIT 1`
+
+	var seq int64
+	if err := r.db.QueryRowContext(ctx, query).Scan(&seq); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return r.GetCheckpoint(ctx, seq)
+}
+
+func (r *PsqlCheckpointRepository) GetCheckpoint(ctx context.Context, seq int64) (*schema.Checkpoint, error) {
+	defer observe(ctx, "checkpoints", "GetCheckpoint")()
+
+	query := `
+		SELECT seq, root, previous_hash, hash, signature, head_count, created_at
+		FROM checkpoints
+		WHERE seq = $1
+	`
+	var checkpoint schema.Checkpoint
+	err := r.db.QueryRowContext(ctx, query, seq).Scan(
+		&checkpoint.Seq,
+		&checkpoint.Root,
+		&checkpoint.PreviousHash,
+		&checkpoint.Hash,
+		&checkpoint.Signature,
+		&checkpoint.HeadCount,
+		&checkpoint.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	headQuery := `
+		SELECT position, contract_id, block_index, block_hash
+		FROM checkpoint_heads
+		WHERE checkpoint_seq = $1
+		ORDER BY position ASC
+	`
+	rows, err := r.db.QueryContext(ctx, headQuery, seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var head schema.CheckpointHead
+		if err := rows.Scan(&head.Position, &head.ContractID, &head.BlockIndex, &head.BlockHash); err != nil {
+			return nil, err
+		}
+		checkpoint.Heads = append(checkpoint.Heads, head)
+	}
+
+	return &checkpoint, rows.Err()
+}
+
+// FindCoveringCheckpoint returns the earliest checkpoint whose head for the
+// contract is at or after the given block.
+func (r *PsqlCheckpointRepository) FindCoveringCheckpoint(ctx context.Context, contractID string, blockIndex int64) (*schema.Checkpoint, error) {
+	defer observe(ctx, "checkpoints", "FindCoveringCheckpoint")()
+
+	query := `
+		SELECT checkpoint_seq
+		FROM checkpoint_heads
+		WHERE contract_id = $1 AND block_index >= $2
+		ORDER BY checkpoint_seq ASC
+		LIMIT 1
+	`
+	var seq int64
+	if err := r.db.QueryRowContext(ctx, query, contractID, blockIndex).Scan(&seq); err != nil {
+		return nil, err
+	}
+
+	return r.GetCheckpoint(ctx, seq)
+}
@@ -16,6 +16,8 @@ type ContractRepository interface {
 	SaveAgentMeta(ctx context.Context, agent *swp.AgentMeta) error
 	GetContractByID(ctx context.Context, id string) (*contracts.Contract, error)
 	GetContractArtifactByHash(ctx context.Context, artifactHash string) (*swp.ArtifactMetadata, error)
+	GetAgentMetaByArtifact(ctx context.Context, artifactHash string) (*swp.AgentMeta, error)
+	ImportContract(ctx context.Context, contract *contracts.Contract, agent *swp.AgentMeta, artifact *swp.ArtifactMetadata, chain []*contracts.Block, head *contracts.ContractHead) error
 }
 
 type PsqlContractRepository struct {
@@ -27,16 +29,28 @@ func NewPsqlContractRepository(db *postgres.DB) ContractRepository {
 }
 
 func (r *PsqlContractRepository) SaveContract(ctx context.Context, contract *contracts.Contract) error {
+	defer observe(ctx, "contracts", "SaveContract")()
+
+	return insertContract(ctx, r.db, contract)
+}
+
+func insertContract(ctx context.Context, db execer, contract *contracts.Contract) error {
 	query := `
-		INSERT INTO contracts (name, owner, artifact_hash, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO contracts (name, owner, artifact_hash, created_at, max_price)
+		VALUES ($1, $2, $3, $4, $5)
 	`
-	_, err := r.db.ExecContext(ctx, query, contract.Name, contract.Owner, contract.ArtifactHash, contract.CreatedAt)
+	_, err := db.ExecContext(ctx, query, contract.Name, contract.Owner, contract.ArtifactHash, contract.CreatedAt, contract.MaxPrice)
 
 	return err
 }
 
 func (r *PsqlContractRepository) SaveContractArtifact(ctx context.Context, artifactHash string, agentHash string, artifact *swp.ArtifactMetadata) error {
+	defer observe(ctx, "contracts", "SaveContractArtifact")()
+
+	return insertContractArtifact(ctx, r.db, artifactHash, agentHash, artifact)
+}
+
+func insertContractArtifact(ctx context.Context, db execer, artifactHash string, agentHash string, artifact *swp.ArtifactMetadata) error {
 	meta := struct {
 		ConstPool    []interface{}          `json:"const_pool"`
 		Functions    map[string]interface{} `json:"functions"`
@@ -58,7 +72,7 @@ func (r *PsqlContractRepository) SaveContractArtifact(ctx context.Context, artif
 
 	query := `INSERT INTO contract_artifacts (bytecode, metadata, _hash, created_at, agent_hash) VALUES ($1, $2, $3, $4, $5)`
 
-	_, err = r.db.ExecContext(
+	_, err = db.ExecContext(
 		ctx,
 		query,
 		artifact.Bytecode,
@@ -72,6 +86,8 @@ func (r *PsqlContractRepository) SaveContractArtifact(ctx context.Context, artif
 }
 
 func (s *PsqlContractRepository) SaveAgentMeta(ctx context.Context, agent *swp.AgentMeta) error {
+	defer observe(ctx, "contracts", "SaveAgentMeta")()
+
 	query := `INSERT INTO contract_agents (_hash, name, version) VALUES ($1, $2, $3)`
 
 	_, err := s.db.ExecContext(
@@ -86,15 +102,17 @@ func (s *PsqlContractRepository) SaveAgentMeta(ctx context.Context, agent *swp.A
 }
 
 func (r *PsqlContractRepository) GetContractByID(ctx context.Context, artifactHash string) (*contracts.Contract, error) {
+	defer observe(ctx, "contracts", "GetContractByID")()
+
 	query := `
-		SELECT id, name, owner, artifact_hash, created_at
+		SELECT id, name, owner, artifact_hash, created_at, max_price
 		FROM contracts
 		WHERE artifact_hash = $1
 	`
 	row := r.db.QueryRowContext(ctx, query, artifactHash)
 
 	var contract contracts.Contract
-	if err := row.Scan(&contract.ID, &contract.Name, &contract.Owner, &contract.ArtifactHash, &contract.CreatedAt); err != nil {
+	if err := row.Scan(&contract.ID, &contract.Name, &contract.Owner, &contract.ArtifactHash, &contract.CreatedAt, &contract.MaxPrice); err != nil {
 		return nil, err
 	}
 
@@ -102,6 +120,8 @@ func (r *PsqlContractRepository) GetContractByID(ctx context.Context, artifactHa
 }
 
 func (r *PsqlContractRepository) GetContractArtifactByHash(ctx context.Context, artifactHash string) (*swp.ArtifactMetadata, error) {
+	defer observe(ctx, "contracts", "GetContractArtifactByHash")()
+
 	query := `
 		SELECT bytecode, metadata
 		FROM contract_artifacts
@@ -123,3 +143,65 @@ func (r *PsqlContractRepository) GetContractArtifactByHash(ctx context.Context,
 
 	return &meta, nil
 }
+
+func (r *PsqlContractRepository) GetAgentMetaByArtifact(ctx context.Context, artifactHash string) (*swp.AgentMeta, error) {
+	defer observe(ctx, "contracts", "GetAgentMetaByArtifact")()
+
+	query := `
+		SELECT a._hash, a.name, a.version
+		FROM contract_artifacts c
+		JOIN contract_agents a ON a._hash = c.agent_hash
+		WHERE c._hash = $1
+		LIMIT 1
+	`
+	row := r.db.QueryRowContext(ctx, query, artifactHash)
+
+	var agent swp.AgentMeta
+	if err := row.Scan(&agent.Hash, &agent.Name, &agent.Version); err != nil {
+		return nil, err
+	}
+
+	return &agent, nil
+}
+
+// ImportContract inserts an exported contract with its artifact, whole
+// chain and signed head in one transaction. The agent is shared between contracts and is
+// only inserted when it is not known yet.
+func (r *PsqlContractRepository) ImportContract(ctx context.Context, contract *contracts.Contract, agent *swp.AgentMeta, artifact *swp.ArtifactMetadata, chain []*contracts.Block, head *contracts.ContractHead) error {
+	defer observe(ctx, "contracts", "ImportContract")()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO contract_agents (_hash, name, version)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM contract_agents WHERE _hash = $1)
+	`
+	if _, err := tx.ExecContext(ctx, query, agent.Hash, agent.Name, agent.Version); err != nil {
+		return err
+	}
+
+	if err := insertContractArtifact(ctx, tx, contract.ArtifactHash, agent.Hash, artifact); err != nil {
+		return err
+	}
+
+	if err := insertContract(ctx, tx, contract); err != nil {
+		return err
+	}
+
+	for _, block := range chain {
+		if err := insertBlock(ctx, tx, block); err != nil {
+			return err
+		}
+	}
+
+	if err := upsertHead(ctx, tx, head); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
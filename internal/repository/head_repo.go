@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type HeadRepository interface {
+	GetHead(ctx context.Context, contractID string) (*schema.ContractHead, error)
+	SaveHead(ctx context.Context, head *schema.ContractHead) error
+	Freeze(ctx context.Context, contractID string, reason string, frozenAt int64) error
+	ListChainContracts(ctx context.Context) ([]string, error)
+}
+
+type PsqlHeadRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlHeadRepository(db *postgres.DB) HeadRepository {
+	return &PsqlHeadRepository{db: db}
+}
+
+// GetHead returns the signed head of a contract, or nil if none was recorded.
+func (r *PsqlHeadRepository) GetHead(ctx context.Context, contractID string) (*schema.ContractHead, error) {
+	defer observe(ctx, "heads", "GetHead")()
+
+	query := `
+		SELECT contract_id, block_index, block_hash, signature, updated_at, frozen, frozen_reason, frozen_at
+		FROM contract_heads
+		WHERE contract_id = $1
+	`
+	row := r.db.QueryRowContext(ctx, query, contractID)
+
+	var head schema.ContractHead
+	err := row.Scan(
+		&head.ContractID,
+		&head.BlockIndex,
+		&head.BlockHash,
+		&head.Signature,
+		&head.UpdatedAt,
+		&head.Frozen,
+		&head.FrozenReason,
+		&head.FrozenAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &head, nil
+}
+
+func (r *PsqlHeadRepository) SaveHead(ctx context.Context, head *schema.ContractHead) error {
+	defer observe(ctx, "heads", "SaveHead")()
+
+	return upsertHead(ctx, r.db, head)
+}
+
+// upsertHead moves the signed head forward. It never clears a freeze.
+func upsertHead(ctx context.Context, db execer, head *schema.ContractHead) error {
+	query := `
+		INSERT INTO contract_heads (contract_id, block_index, block_hash, signature, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (contract_id) DO UPDATE
+		SET block_index = EXCLUDED.block_index,
+			block_hash = EXCLUDED.block_hash,
+			signature = EXCLUDED.signature,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := db.ExecContext(ctx, query,
+		head.ContractID,
+		head.BlockIndex,
+		head.BlockHash,
+		head.Signature,
+		head.UpdatedAt,
+	)
+
+	return err
+}
+
+// Freeze marks a contract frozen. A contract without a head record yet gets
+// one holding no signature, so the freeze still sticks.
+func (r *PsqlHeadRepository) Freeze(ctx context.Context, contractID string, reason string, frozenAt int64) error {
+	defer observe(ctx, "heads", "Freeze")()
+
+	query := `
+		INSERT INTO contract_heads (contract_id, block_index, block_hash, signature, updated_at, frozen, frozen_reason, frozen_at)
+		VALUES ($1, 0, '', '', $3, TRUE, $2, $3)
+		ON CONFLICT (contract_id) DO UPDATE
+		SET frozen = TRUE,
+			frozen_reason = EXCLUDED.frozen_reason,
+			frozen_at = EXCLUDED.frozen_at
+		WHERE NOT contract_heads.frozen
+	`
+	_, err := r.db.ExecContext(ctx, query, contractID, reason, frozenAt)
+
+	return err
+}
+
+func (r *PsqlHeadRepository) ListChainContracts(ctx context.Context) ([]string, error) {
+	defer observe(ctx, "heads", "ListChainContracts")()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT contract_id FROM blocks ORDER BY contract_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, record *schema.IdempotencyRecord, expiredBefore int64, abandonedBefore int64) (bool, error)
+	Get(ctx context.Context, identity string, key string) (*schema.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *schema.IdempotencyRecord) error
+	Release(ctx context.Context, record *schema.IdempotencyRecord) error
+	DeleteExpired(ctx context.Context, before int64) error
+}
+
+type PsqlIdempotencyRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlIdempotencyRepository(db *postgres.DB) IdempotencyRepository {
+	return &PsqlIdempotencyRepository{db: db}
+}
+
+// Reserve claims the key for a new request. It returns false when the key is
+// already taken, either by a completed request or one still in flight. A key
+// created before expiredBefore is taken over, since the sweep that deletes
+// expired keys may not have reached it yet, and so is a reservation made
+// before abandonedBefore that never completed, whose request must have died.
+func (r *PsqlIdempotencyRepository) Reserve(ctx context.Context, record *schema.IdempotencyRecord, expiredBefore int64, abandonedBefore int64) (bool, error) {
+	defer observe(ctx, "idempotency", "Reserve")()
+
+	query := `
+		INSERT INTO idempotency_keys (identity, idempotency_key, route, request_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (identity, idempotency_key) DO UPDATE
+		SET route = EXCLUDED.route,
+			request_hash = EXCLUDED.request_hash,
+			status_code = 0,
+			content_type = '',
+			response = NULL,
+			completed = FALSE,
+			created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at < $6
+		   OR (NOT idempotency_keys.completed AND idempotency_keys.created_at < $7)
+	`
+	res, err := r.db.ExecContext(ctx, query, record.Identity, record.Key, record.Route, record.RequestHash, record.CreatedAt, expiredBefore, abandonedBefore)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (r *PsqlIdempotencyRepository) Get(ctx context.Context, identity string, key string) (*schema.IdempotencyRecord, error) {
+	defer observe(ctx, "idempotency", "Get")()
+
+	query := `
+		SELECT identity, idempotency_key, route, request_hash, status_code, content_type, response, completed, created_at
+		FROM idempotency_keys
+		WHERE identity = $1 AND idempotency_key = $2
+	`
+	row := r.db.QueryRowContext(ctx, query, identity, key)
+
+	var record schema.IdempotencyRecord
+	err := row.Scan(
+		&record.Identity,
+		&record.Key,
+		&record.Route,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.Response,
+		&record.Completed,
+		&record.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Complete stores the response of a reservation. A reservation that was
+// taken over by a later request is left alone.
+func (r *PsqlIdempotencyRepository) Complete(ctx context.Context, record *schema.IdempotencyRecord) error {
+	defer observe(ctx, "idempotency", "Complete")()
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $4, content_type = $5, response = $6, completed = TRUE
+		WHERE identity = $1 AND idempotency_key = $2 AND created_at = $3 AND NOT completed
+	`
+	_, err := r.db.ExecContext(ctx, query, record.Identity, record.Key, record.CreatedAt, record.StatusCode, record.ContentType, record.Response)
+
+	return err
+}
+
+// Release deletes a reservation that has not completed, unless it was taken
+// over by a later request.
+func (r *PsqlIdempotencyRepository) Release(ctx context.Context, record *schema.IdempotencyRecord) error {
+	defer observe(ctx, "idempotency", "Release")()
+
+	query := `DELETE FROM idempotency_keys WHERE identity = $1 AND idempotency_key = $2 AND created_at = $3 AND NOT completed`
+	_, err := r.db.ExecContext(ctx, query, record.Identity, record.Key, record.CreatedAt)
+
+	return err
+}
+
+func (r *PsqlIdempotencyRepository) DeleteExpired(ctx context.Context, before int64) error {
+	defer observe(ctx, "idempotency", "DeleteExpired")()
+
+	query := `DELETE FROM idempotency_keys WHERE created_at < $1`
+	_, err := r.db.ExecContext(ctx, query, before)
+
+	return err
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type JobRepository interface {
+	SaveJob(ctx context.Context, job *schema.Job) error
+	GetJob(ctx context.Context, id string) (*schema.Job, error)
+	ClaimJob(ctx context.Context, id string, leaseUntil int64) (*schema.Job, error)
+	ExtendJob(ctx context.Context, id string, leaseUntil int64) error
+	CompleteJob(ctx context.Context, job *schema.Job) error
+	ListQueuedJobs(ctx context.Context, updatedBefore int64, limit int) ([]*schema.Job, error)
+	FailExpiredJobs(ctx context.Context, now int64, code string, message string) (int64, error)
+}
+
+type PsqlJobRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlJobRepository(db *postgres.DB) JobRepository {
+	return &PsqlJobRepository{db: db}
+}
+
+func (r *PsqlJobRepository) SaveJob(ctx context.Context, job *schema.Job) error {
+	defer observe(ctx, "jobs", "SaveJob")()
+
+	query := `
+		INSERT INTO jobs (id, contract_id, function_name, identity, payload, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		job.ID,
+		job.ContractID,
+		job.FunctionName,
+		job.Identity,
+		job.Payload,
+		job.Status,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *PsqlJobRepository) GetJob(ctx context.Context, id string) (*schema.Job, error) {
+	defer observe(ctx, "jobs", "GetJob")()
+
+	query := `
+		SELECT id, contract_id, function_name, identity, payload, status, result, error_code, error, block_index, block_hash, created_at, updated_at
+		FROM jobs
+		WHERE id = $1
+	`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	var job schema.Job
+	err := row.Scan(
+		&job.ID,
+		&job.ContractID,
+		&job.FunctionName,
+		&job.Identity,
+		&job.Payload,
+		&job.Status,
+		&job.Result,
+		&job.ErrorCode,
+		&job.Error,
+		&job.BlockIndex,
+		&job.BlockHash,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ClaimJob moves a queued job to running and leases it until leaseUntil.
+// It returns sql.ErrNoRows when the job is not queued, e.g. because another
+// worker or replica claimed it first.
+func (r *PsqlJobRepository) ClaimJob(ctx context.Context, id string, leaseUntil int64) (*schema.Job, error) {
+	defer observe(ctx, "jobs", "ClaimJob")()
+
+	query := `
+		UPDATE jobs
+		SET status = 'running', lease_until = $2, updated_at = $3
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE id = $1 AND status = 'queued'
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, contract_id, function_name, identity, payload, status, created_at, updated_at
+	`
+	row := r.db.QueryRowContext(ctx, query, id, leaseUntil, time.Now().UTC().UnixMilli())
+
+	var job schema.Job
+	err := row.Scan(
+		&job.ID,
+		&job.ContractID,
+		&job.FunctionName,
+		&job.Identity,
+		&job.Payload,
+		&job.Status,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ExtendJob moves the lease of a running job to leaseUntil.
+func (r *PsqlJobRepository) ExtendJob(ctx context.Context, id string, leaseUntil int64) error {
+	defer observe(ctx, "jobs", "ExtendJob")()
+
+	query := `UPDATE jobs SET lease_until = $2 WHERE id = $1 AND status = 'running'`
+	_, err := r.db.ExecContext(ctx, query, id, leaseUntil)
+
+	return err
+}
+
+func (r *PsqlJobRepository) CompleteJob(ctx context.Context, job *schema.Job) error {
+	defer observe(ctx, "jobs", "CompleteJob")()
+
+	query := `
+		UPDATE jobs
+		SET status = $2, result = $3, error_code = $4, error = $5, block_index = $6, block_hash = $7, updated_at = $8
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		job.ID,
+		job.Status,
+		job.Result,
+		job.ErrorCode,
+		job.Error,
+		job.BlockIndex,
+		job.BlockHash,
+		job.UpdatedAt,
+	)
+
+	return err
+}
+
+// ListQueuedJobs returns queued jobs last touched before updatedBefore,
+// oldest first. Listing does not claim them.
+func (r *PsqlJobRepository) ListQueuedJobs(ctx context.Context, updatedBefore int64, limit int) ([]*schema.Job, error) {
+	defer observe(ctx, "jobs", "ListQueuedJobs")()
+
+	query := `
+		SELECT id, contract_id, function_name, status, created_at, updated_at
+		FROM jobs
+		WHERE status = 'queued' AND updated_at < $1
+		ORDER BY created_at ASC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, updatedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*schema.Job
+	for rows.Next() {
+		var job schema.Job
+		if err := rows.Scan(&job.ID, &job.ContractID, &job.FunctionName, &job.Status, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// FailExpiredJobs fails running jobs whose lease ran out. Their worker died
+// mid-execution, so whether a block was written is unknown and the job is
+// not run again.
+func (r *PsqlJobRepository) FailExpiredJobs(ctx context.Context, now int64, code string, message string) (int64, error) {
+	defer observe(ctx, "jobs", "FailExpiredJobs")()
+
+	query := `
+		UPDATE jobs
+		SET status = 'failed', error_code = $2, error = $3, updated_at = $1
+		WHERE status = 'running' AND lease_until < $1
+	`
+	res, err := r.db.ExecContext(ctx, query, now, code, message)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/metrics"
+	"github.com/peiblow/eeapi/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// observe times, traces and debug-logs a repository method; call the
+// returned func when the method returns.
+func observe(ctx context.Context, repository string, method string) func() {
+	done := metrics.ObserveQuery(repository, method)
+	_, span := tracing.Start(ctx, repository+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", method),
+		),
+	)
+
+	start := time.Now()
+	return func() {
+		span.End()
+		done()
+		logging.FromContext(ctx).Debug("Database query", "repository", repository, "method", method, "duration", time.Since(start))
+	}
+}
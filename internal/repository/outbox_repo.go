@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+// outboxClaimLockKey serialises claims across relays.
+const outboxClaimLockKey = 0x6f757462
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, event events.Event) error
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*schema.OutboxEntry, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryAfter time.Duration) error
+	Release(ctx context.Context, id int64) error
+}
+
+type PsqlOutboxRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlOutboxRepository(db *postgres.DB) OutboxRepository {
+	return &PsqlOutboxRepository{db: db}
+}
+
+// execer is satisfied by both *postgres.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (r *PsqlOutboxRepository) Enqueue(ctx context.Context, event events.Event) error {
+	defer observe(ctx, "outbox", "Enqueue")()
+
+	return insertOutbox(ctx, r.db, event)
+}
+
+// Claim leases up to limit undelivered entries, oldest first. Entries leased
+// by another relay are skipped until their lease runs out, so a crashed relay
+// only delays delivery. An entry is not claimed while an earlier entry of its
+// contract is leased or waiting to be retried, so each contract's events are
+// delivered in order. Claims are serialised across relays, since SKIP LOCKED
+// would otherwise hide an earlier entry another relay is claiming.
+func (r *PsqlOutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*schema.OutboxEntry, error) {
+	defer observe(ctx, "outbox", "Claim")()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, outboxClaimLockKey); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE outbox SET locked_until = $1, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE delivered_at IS NULL AND locked_until < $2
+			  AND NOT EXISTS (
+				SELECT 1 FROM outbox earlier
+				WHERE earlier.contract_id = outbox.contract_id
+				  AND earlier.id < outbox.id
+				  AND earlier.delivered_at IS NULL
+				  AND earlier.locked_until >= $2
+			  )
+			ORDER BY id ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_id, event_type, contract_id, payload, attempts, last_error, created_at
+	`
+	rows, err := tx.QueryContext(ctx, query, now.Add(lease).UnixMilli(), now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*schema.OutboxEntry
+	for rows.Next() {
+		var entry schema.OutboxEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.EventID,
+			&entry.EventType,
+			&entry.ContractID,
+			&entry.Payload,
+			&entry.Attempts,
+			&entry.LastError,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING gives no ordering guarantee.
+	sortOutbox(entries)
+
+	return entries, nil
+}
+
+func (r *PsqlOutboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	defer observe(ctx, "outbox", "MarkDelivered")()
+
+	query := `UPDATE outbox SET delivered_at = $2, last_error = '' WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, time.Now().UTC().UnixMilli())
+
+	return err
+}
+
+func (r *PsqlOutboxRepository) MarkFailed(ctx context.Context, id int64, reason string, retryAfter time.Duration) error {
+	defer observe(ctx, "outbox", "MarkFailed")()
+
+	query := `UPDATE outbox SET last_error = $2, locked_until = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, reason, time.Now().UTC().Add(retryAfter).UnixMilli())
+
+	return err
+}
+
+// Release gives back a claimed entry that was not attempted.
+func (r *PsqlOutboxRepository) Release(ctx context.Context, id int64) error {
+	defer observe(ctx, "outbox", "Release")()
+
+	query := `UPDATE outbox SET locked_until = 0, attempts = attempts - 1 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+
+	return err
+}
+
+func insertOutbox(ctx context.Context, db execer, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox (event_id, event_type, contract_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = db.ExecContext(ctx, query, event.ID, event.Type, event.ContractID, payload, time.Now().UTC().UnixMilli())
+
+	return err
+}
+
+func sortOutbox(entries []*schema.OutboxEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type QuotaRepository interface {
+	GetQuota(ctx context.Context, identity string) (*schema.Quota, error)
+	CountExecution(ctx context.Context, identity string, day string, limit int64) (bool, error)
+	UncountExecution(ctx context.Context, identity string, day string) error
+	GetExecutions(ctx context.Context, identity string, day string) (int64, error)
+}
+
+type PsqlQuotaRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlQuotaRepository(db *postgres.DB) QuotaRepository {
+	return &PsqlQuotaRepository{db: db}
+}
+
+// GetQuota returns the stored quota of an identity, or nil if it has none.
+func (r *PsqlQuotaRepository) GetQuota(ctx context.Context, identity string) (*schema.Quota, error) {
+	defer observe(ctx, "quotas", "GetQuota")()
+
+	query := `
+		SELECT identity, daily_executions, exec_price_budget, exec_price_spent
+		FROM quotas
+		WHERE identity = $1
+	`
+	row := r.db.QueryRowContext(ctx, query, identity)
+
+	var quota schema.Quota
+	var daily, budget sql.NullInt64
+	err := row.Scan(&quota.Identity, &daily, &budget, &quota.ExecPriceSpent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if daily.Valid {
+		quota.DailyExecutions = &daily.Int64
+	}
+	if budget.Valid {
+		quota.ExecPriceBudget = &budget.Int64
+	}
+
+	return &quota, nil
+}
+
+// CountExecution counts one execution for the day unless that would take it
+// past limit. A limit of zero or less never refuses.
+func (r *PsqlQuotaRepository) CountExecution(ctx context.Context, identity string, day string, limit int64) (bool, error) {
+	defer observe(ctx, "quotas", "CountExecution")()
+
+	query := `
+		INSERT INTO quota_executions (identity, day, executions)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (identity, day) DO UPDATE
+		SET executions = quota_executions.executions + 1
+		WHERE $3::bigint <= 0 OR quota_executions.executions < $3::bigint
+		RETURNING executions
+	`
+	var executions int64
+	err := r.db.QueryRowContext(ctx, query, identity, day, limit).Scan(&executions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *PsqlQuotaRepository) UncountExecution(ctx context.Context, identity string, day string) error {
+	defer observe(ctx, "quotas", "UncountExecution")()
+
+	query := `
+		UPDATE quota_executions
+		SET executions = executions - 1
+		WHERE identity = $1 AND day = $2 AND executions > 0
+	`
+	_, err := r.db.ExecContext(ctx, query, identity, day)
+
+	return err
+}
+
+func (r *PsqlQuotaRepository) GetExecutions(ctx context.Context, identity string, day string) (int64, error) {
+	defer observe(ctx, "quotas", "GetExecutions")()
+
+	query := `SELECT executions FROM quota_executions WHERE identity = $1 AND day = $2`
+
+	var executions int64
+	err := r.db.QueryRowContext(ctx, query, identity, day).Scan(&executions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return executions, err
+}
+
+// addSpend charges price to the identity's ExecPrice budget.
+func addSpend(ctx context.Context, db execer, identity string, price int64) error {
+	if price <= 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO quotas (identity, exec_price_spent)
+		VALUES ($1, $2)
+		ON CONFLICT (identity) DO UPDATE
+		SET exec_price_spent = quotas.exec_price_spent + EXCLUDED.exec_price_spent
+	`
+	_, err := db.ExecContext(ctx, query, identity, price)
+
+	return err
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type UsageRepository interface {
+	Aggregate(ctx context.Context, from int64, to int64, groupBy schema.UsageGroup) ([]*schema.UsageRow, error)
+}
+
+type PsqlUsageRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlUsageRepository(db *postgres.DB) UsageRepository {
+	return &PsqlUsageRepository{db: db}
+}
+
+// usageGroupColumns lists the ledger columns each grouping aggregates over.
+var usageGroupColumns = map[schema.UsageGroup][]string{
+	schema.UsageByContract: {"contract_id", "owner"},
+	schema.UsageByFunction: {"contract_id", "function_name"},
+	schema.UsageByCaller:   {"caller"},
+	schema.UsageByOwner:    {"owner"},
+}
+
+func insertUsage(ctx context.Context, db execer, entry *schema.UsageEntry) error {
+	query := `
+		INSERT INTO usage_ledger (contract_id, owner, caller, function_name, block_index, block_hash, exec_price, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := db.ExecContext(ctx, query,
+		entry.ContractID,
+		entry.Owner,
+		entry.Caller,
+		entry.FunctionName,
+		entry.BlockIndex,
+		entry.BlockHash,
+		entry.ExecPrice,
+		entry.CreatedAt,
+	)
+
+	return err
+}
+
+// Aggregate sums the ledger entries created in [from, to), most expensive
+// group first.
+func (r *PsqlUsageRepository) Aggregate(ctx context.Context, from int64, to int64, groupBy schema.UsageGroup) ([]*schema.UsageRow, error) {
+	defer observe(ctx, "usage", "Aggregate")()
+
+	columns, ok := usageGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown usage grouping %q", groupBy)
+	}
+
+	selected := make([]string, 0, 4)
+	for _, column := range []string{"contract_id", "owner", "function_name", "caller"} {
+		if slices.Contains(columns, column) {
+			selected = append(selected, column)
+		} else {
+			selected = append(selected, "''")
+		}
+	}
+
+	query := `
+		SELECT ` + strings.Join(selected, ", ") + `, COUNT(*), COALESCE(SUM(exec_price), 0)
+		FROM usage_ledger
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY ` + strings.Join(columns, ", ") + `
+		ORDER BY 6 DESC, 5 DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []*schema.UsageRow
+	for rows.Next() {
+		var row schema.UsageRow
+		if err := rows.Scan(&row.ContractID, &row.Owner, &row.Function, &row.Caller, &row.Executions, &row.ExecPrice); err != nil {
+			return nil, err
+		}
+		usage = append(usage, &row)
+	}
+
+	return usage, rows.Err()
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type WebhookRepository interface {
+	SaveSubscription(ctx context.Context, sub *schema.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (*schema.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context, identity string) ([]*schema.WebhookSubscription, error)
+	ListMatchingSubscriptions(ctx context.Context, contractID string, function string) ([]*schema.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, identity string, id string) (bool, error)
+	EnqueueDelivery(ctx context.Context, delivery *schema.WebhookDelivery) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*schema.WebhookDelivery, error)
+	DeleteDelivery(ctx context.Context, id int64) error
+	RetryDelivery(ctx context.Context, id int64, status int, reason string, retryAfter time.Duration) error
+	DeadLetterDelivery(ctx context.Context, id int64, letter *schema.WebhookDeadLetter) error
+}
+
+type PsqlWebhookRepository struct {
+	db *postgres.DB
+}
+
+func NewPsqlWebhookRepository(db *postgres.DB) WebhookRepository {
+	return &PsqlWebhookRepository{db: db}
+}
+
+const webhookColumns = `id, identity, url, secret, contract_id, function_name, event_types, created_at`
+
+func (r *PsqlWebhookRepository) SaveSubscription(ctx context.Context, sub *schema.WebhookSubscription) error {
+	defer observe(ctx, "webhooks", "SaveSubscription")()
+
+	query := `
+		INSERT INTO webhook_subscriptions (id, identity, url, secret, contract_id, function_name, event_types, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		sub.ID,
+		sub.Identity,
+		sub.URL,
+		sub.Secret,
+		sub.ContractID,
+		sub.Function,
+		pq.Array(sub.EventTypes),
+		sub.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *PsqlWebhookRepository) GetSubscription(ctx context.Context, id string) (*schema.WebhookSubscription, error) {
+	defer observe(ctx, "webhooks", "GetSubscription")()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	var sub schema.WebhookSubscription
+	if err := row.Scan(&sub.ID, &sub.Identity, &sub.URL, &sub.Secret, &sub.ContractID, &sub.Function, pq.Array(&sub.EventTypes), &sub.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// ListSubscriptions returns the subscriptions created by identity.
+func (r *PsqlWebhookRepository) ListSubscriptions(ctx context.Context, identity string) ([]*schema.WebhookSubscription, error) {
+	defer observe(ctx, "webhooks", "ListSubscriptions")()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions WHERE identity = $1 ORDER BY created_at ASC`
+
+	return r.querySubscriptions(ctx, query, identity)
+}
+
+// ListMatchingSubscriptions returns the global subscriptions plus the ones
+// scoped to the contract, or to the contract and function.
+func (r *PsqlWebhookRepository) ListMatchingSubscriptions(ctx context.Context, contractID string, function string) ([]*schema.WebhookSubscription, error) {
+	defer observe(ctx, "webhooks", "ListMatchingSubscriptions")()
+
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhook_subscriptions
+		WHERE (contract_id = '' OR contract_id = $1)
+		  AND (function_name = '' OR function_name = $2)
+		ORDER BY created_at ASC
+	`
+
+	return r.querySubscriptions(ctx, query, contractID, function)
+}
+
+// DeleteSubscription deletes a subscription if identity created it.
+func (r *PsqlWebhookRepository) DeleteSubscription(ctx context.Context, identity string, id string) (bool, error) {
+	defer observe(ctx, "webhooks", "DeleteSubscription")()
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND identity = $2`, id, identity)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// EnqueueDelivery queues an event for a subscription. Queuing the same event
+// for the same subscription again is a no-op, so a republished outbox entry
+// is not delivered twice.
+func (r *PsqlWebhookRepository) EnqueueDelivery(ctx context.Context, delivery *schema.WebhookDelivery) error {
+	defer observe(ctx, "webhooks", "EnqueueDelivery")()
+
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		delivery.SubscriptionID,
+		delivery.EventID,
+		delivery.EventType,
+		delivery.Payload,
+		delivery.CreatedAt,
+	)
+
+	return err
+}
+
+// ClaimDeliveries leases up to limit deliveries that are due, oldest first.
+// A delivery whose dispatcher died is claimed again once its lease runs out.
+func (r *PsqlWebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*schema.WebhookDelivery, error) {
+	defer observe(ctx, "webhooks", "ClaimDeliveries")()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE webhook_deliveries SET locked_until = $1, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE locked_until < $2
+			ORDER BY id ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, subscription_id, event_id, event_type, payload, attempts, last_status, last_error, created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, now.Add(lease).UnixMilli(), now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*schema.WebhookDelivery
+	for rows.Next() {
+		var delivery schema.WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.SubscriptionID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Attempts,
+			&delivery.LastStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *PsqlWebhookRepository) DeleteDelivery(ctx context.Context, id int64) error {
+	defer observe(ctx, "webhooks", "DeleteDelivery")()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = $1`, id)
+
+	return err
+}
+
+// RetryDelivery records a failed attempt and makes the delivery due again
+// after retryAfter.
+func (r *PsqlWebhookRepository) RetryDelivery(ctx context.Context, id int64, status int, reason string, retryAfter time.Duration) error {
+	defer observe(ctx, "webhooks", "RetryDelivery")()
+
+	query := `UPDATE webhook_deliveries SET last_status = $2, last_error = $3, locked_until = $4 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, status, reason, time.Now().UTC().Add(retryAfter).UnixMilli())
+
+	return err
+}
+
+// DeadLetterDelivery moves a delivery that ran out of attempts to the
+// dead-letter table.
+func (r *PsqlWebhookRepository) DeadLetterDelivery(ctx context.Context, id int64, letter *schema.WebhookDeadLetter) error {
+	defer observe(ctx, "webhooks", "DeadLetterDelivery")()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO webhook_dead_letters (subscription_id, event_id, event_type, payload, attempts, last_status, last_error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		letter.SubscriptionID,
+		letter.EventID,
+		letter.EventType,
+		letter.Payload,
+		letter.Attempts,
+		letter.LastStatus,
+		letter.LastError,
+		letter.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PsqlWebhookRepository) querySubscriptions(ctx context.Context, query string, args ...any) ([]*schema.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*schema.WebhookSubscription
+	for rows.Next() {
+		var sub schema.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.Identity, &sub.URL, &sub.Secret, &sub.ContractID, &sub.Function, pq.Array(&sub.EventTypes), &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, &sub)
+	}
+
+	return subs, rows.Err()
+}
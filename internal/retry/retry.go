@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff grows exponentially from Base up to Max. Delays are jittered so
+// replicas restarting together do not retry in lockstep.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the wait before the given attempt, counting from 1. It is a
+// random duration between half and all of the exponential delay.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Base <= 0 {
+		b.Base = time.Second
+	}
+	if b.Max < b.Base {
+		b.Max = b.Base
+	}
+
+	d := b.Base << min(max(attempt-1, 0), 30)
+	if d <= 0 || d > b.Max {
+		d = b.Max
+	}
+
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// Do calls fn until it succeeds or ctx is done, waiting the backoff delay
+// between attempts. onRetry, if set, is told about each failure.
+func Do(ctx context.Context, b Backoff, fn func(context.Context) error, onRetry func(attempt int, err error, delay time.Duration)) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		delay := b.Delay(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
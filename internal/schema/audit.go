@@ -0,0 +1,32 @@
+package schema
+
+// AuditEvent records one audited API call. Events are chained: Hash covers
+// the event and PreviousHash, the hash of the event before it.
+type AuditEvent struct {
+	Seq          int64  `json:"seq"`
+	RequestID    string `json:"request_id"`
+	Actor        string `json:"actor"`
+	Method       string `json:"method"`
+	Route        string `json:"route"`
+	Path         string `json:"path"`
+	ContractID   string `json:"contract_id,omitempty"`
+	Function     string `json:"function,omitempty"`
+	Status       int    `json:"status"`
+	Outcome      string `json:"outcome"`
+	RemoteAddr   string `json:"remote_addr"`
+	CreatedAt    int64  `json:"created_at"`
+	PreviousHash string `json:"previous_hash"`
+	Hash         string `json:"hash"`
+}
+
+// AuditFilter selects audit events. Zero fields do not filter; events come
+// in sequence order starting after AfterSeq.
+type AuditFilter struct {
+	Actor      string
+	ContractID string
+	Outcome    string
+	From       int64
+	To         int64
+	AfterSeq   int64
+	Limit      int
+}
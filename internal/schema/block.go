@@ -9,5 +9,12 @@ type Block struct {
 	Signature    []byte `json:"signature"`
 	ContractID   string `json:"contract_id"`
 	FunctionName string `json:"function_name"`
-	Journal      []byte `json:"journal"`
+	ArgsHash     string `json:"args_hash"`
+	JournalRoot  string `json:"journal_root"`
+	ArtifactHash string `json:"artifact_hash"`
+	HashVersion  int    `json:"hash_version"`
+	// ExecPrice is what the VM charged for the execution. It is recorded for
+	// accounting only and is not part of the block hash.
+	ExecPrice int64  `json:"exec_price"`
+	Journal   []byte `json:"journal"`
 }
@@ -0,0 +1,19 @@
+package schema
+
+type Checkpoint struct {
+	Seq          int64            `json:"seq"`
+	Root         string           `json:"root"`
+	PreviousHash string           `json:"previous_hash"`
+	Hash         string           `json:"hash"`
+	Signature    []byte           `json:"signature"`
+	HeadCount    int              `json:"head_count"`
+	CreatedAt    int64            `json:"created_at"`
+	Heads        []CheckpointHead `json:"heads,omitempty"`
+}
+
+type CheckpointHead struct {
+	Position   int    `json:"position"`
+	ContractID string `json:"contract_id"`
+	BlockIndex int64  `json:"block_index"`
+	BlockHash  string `json:"block_hash"`
+}
@@ -9,5 +9,8 @@ type Contract struct {
 
 	ArtifactHash string `json:"artifact_hash"`
 
+	// MaxPrice is the execution price limit used when a caller sets none.
+	MaxPrice int64 `json:"max_price"`
+
 	CreatedAt int64 `json:"created_at"`
 }
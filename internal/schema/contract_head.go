@@ -0,0 +1,15 @@
+package schema
+
+// ContractHead is the node-signed record of the last block of a contract
+// chain. It is kept apart from the blocks so that rewriting the chain
+// without the node key is noticed.
+type ContractHead struct {
+	ContractID   string `json:"contract_id"`
+	BlockIndex   int64  `json:"block_index"`
+	BlockHash    string `json:"block_hash"`
+	Signature    []byte `json:"signature"`
+	UpdatedAt    int64  `json:"updated_at"`
+	Frozen       bool   `json:"frozen"`
+	FrozenReason string `json:"frozen_reason,omitempty"`
+	FrozenAt     int64  `json:"frozen_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package schema
+
+type IdempotencyRecord struct {
+	Identity    string `json:"identity"`
+	Key         string `json:"idempotency_key"`
+	Route       string `json:"route"`
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Response    []byte `json:"response"`
+	Completed   bool   `json:"completed"`
+	CreatedAt   int64  `json:"created_at"`
+}
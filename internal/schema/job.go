@@ -0,0 +1,26 @@
+package schema
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+type Job struct {
+	ID           string    `json:"id"`
+	ContractID   string    `json:"contract_id"`
+	FunctionName string    `json:"function_name"`
+	Identity     string    `json:"identity"`
+	Payload      []byte    `json:"payload"`
+	Status       JobStatus `json:"status"`
+	Result       []byte    `json:"result"`
+	ErrorCode    string    `json:"error_code"`
+	Error        string    `json:"error"`
+	BlockIndex   int64     `json:"block_index"`
+	BlockHash    string    `json:"block_hash"`
+	CreatedAt    int64     `json:"created_at"`
+	UpdatedAt    int64     `json:"updated_at"`
+}
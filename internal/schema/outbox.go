@@ -0,0 +1,13 @@
+package schema
+
+type OutboxEntry struct {
+	ID          int64  `json:"id"`
+	EventID     string `json:"event_id"`
+	EventType   string `json:"event_type"`
+	ContractID  string `json:"contract_id"`
+	Payload     []byte `json:"payload"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"last_error"`
+	CreatedAt   int64  `json:"created_at"`
+	DeliveredAt int64  `json:"delivered_at"`
+}
@@ -0,0 +1,20 @@
+package schema
+
+// Quota holds the limits stored for one identity. A nil limit falls back to
+// the configured default; zero means unlimited.
+type Quota struct {
+	Identity        string `json:"identity"`
+	DailyExecutions *int64 `json:"daily_executions"`
+	ExecPriceBudget *int64 `json:"exec_price_budget"`
+	ExecPriceSpent  int64  `json:"exec_price_spent"`
+}
+
+// QuotaUsage is an identity's consumption against its effective limits.
+type QuotaUsage struct {
+	Identity        string `json:"identity"`
+	Day             string `json:"day"`
+	Executions      int64  `json:"executions"`
+	DailyExecutions int64  `json:"daily_executions"`
+	ExecPriceSpent  int64  `json:"exec_price_spent"`
+	ExecPriceBudget int64  `json:"exec_price_budget"`
+}
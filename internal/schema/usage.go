@@ -0,0 +1,33 @@
+package schema
+
+// UsageEntry records what one execution cost and who it is billed to.
+type UsageEntry struct {
+	ContractID   string `json:"contract_id"`
+	Owner        string `json:"owner"`
+	Caller       string `json:"caller"`
+	FunctionName string `json:"function_name"`
+	BlockIndex   int64  `json:"block_index"`
+	BlockHash    string `json:"block_hash"`
+	ExecPrice    int64  `json:"exec_price"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// UsageRow aggregates the ledger over one group. Only the fields the rows
+// are grouped by are set.
+type UsageRow struct {
+	ContractID string `json:"contract_id,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	Function   string `json:"function,omitempty"`
+	Caller     string `json:"caller,omitempty"`
+	Executions int64  `json:"executions"`
+	ExecPrice  int64  `json:"exec_price"`
+}
+
+type UsageGroup string
+
+const (
+	UsageByContract UsageGroup = "contract"
+	UsageByFunction UsageGroup = "function"
+	UsageByCaller   UsageGroup = "caller"
+	UsageByOwner    UsageGroup = "owner"
+)
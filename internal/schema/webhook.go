@@ -0,0 +1,53 @@
+package schema
+
+type WebhookSubscription struct {
+	ID         string   `json:"id"`
+	Identity   string   `json:"-"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	ContractID string   `json:"contract_id,omitempty"`
+	Function   string   `json:"function,omitempty"`
+	EventTypes []string `json:"event_types,omitempty"`
+	CreatedAt  int64    `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants events of the given type.
+// An empty event type list subscribes to every event.
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery is an event queued for one subscription until it is
+// delivered or moved to the dead letters.
+type WebhookDelivery struct {
+	ID             int64  `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	Payload        []byte `json:"payload"`
+	Attempts       int    `json:"attempts"`
+	LastStatus     int    `json:"last_status"`
+	LastError      string `json:"last_error"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+type WebhookDeadLetter struct {
+	SubscriptionID string `json:"subscription_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	Payload        []byte `json:"payload"`
+	Attempts       int    `json:"attempts"`
+	LastStatus     int    `json:"last_status"`
+	LastError      string `json:"last_error"`
+	CreatedAt      int64  `json:"created_at"`
+}
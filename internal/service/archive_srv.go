@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/archive"
+	"github.com/peiblow/eeapi/internal/blocks"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/repository"
+)
+
+const chainPageSize = 1000
+
+type ArchiveService interface {
+	Export(ctx context.Context, contractID string, withJournals bool) (*archive.Archive, error)
+	Import(ctx context.Context, a *archive.Archive, pub ed25519.PublicKey) (*ImportResult, error)
+}
+
+type ImportResult struct {
+	ContractID string `json:"contract_id"`
+	Blocks     int    `json:"blocks"`
+	HeadIndex  int64  `json:"head_index"`
+	HeadHash   string `json:"head_hash"`
+}
+
+type archiveService struct {
+	db      repository.ContractRepository
+	blockDB repository.BlockRepository
+	privKey []byte
+	pubKey  []byte
+}
+
+func NewArchiveService(db *postgres.DB, privKey []byte, pubKey []byte) ArchiveService {
+	return &archiveService{
+		db:      repository.NewPsqlContractRepository(db),
+		blockDB: repository.NewPsqlBlockRepository(db),
+		privKey: privKey,
+		pubKey:  pubKey,
+	}
+}
+
+func (s *archiveService) Export(ctx context.Context, contractID string, withJournals bool) (*archive.Archive, error) {
+	contract, err := s.db.GetContractByID(ctx, contractID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.CodeNotFound, "contract not found", err)
+		}
+		return nil, err
+	}
+
+	artifact, err := s.db.GetContractArtifactByHash(ctx, contract.ArtifactHash)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := s.db.GetAgentMetaByArtifact(ctx, contract.ArtifactHash)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &archive.Archive{
+		Format:     archive.Format,
+		Version:    archive.Version,
+		ExportedAt: time.Now().UTC().UnixMilli(),
+		Contract:   *contract,
+		Agent:      *agent,
+		Artifact:   *artifact,
+		PublicKeys: []string{"0x" + hex.EncodeToString(s.pubKey)},
+	}
+
+	if a.Blocks, err = loadChain(ctx, s.blockDB, contractID); err != nil {
+		return nil, err
+	}
+
+	if withJournals {
+		a.Journals = make(map[int64]json.RawMessage)
+		for _, block := range a.Blocks {
+			if blocks.IsGenesis(block) {
+				continue
+			}
+
+			journal, err := keys.DecryptJournal(block.Journal, s.privKey)
+			if err != nil {
+				return nil, err
+			}
+			a.Journals[block.BlockIndex] = journal
+		}
+	}
+
+	logging.FromContext(ctx).Info("Contract exported", "contract_id", contractID, "blocks", len(a.Blocks), "journals", withJournals)
+	return a, nil
+}
+
+// Import verifies the archived chain, against pub or else this node's own
+// key, and inserts the contract with its whole history. Archived journals are
+// re-encrypted with this node's key so they stay readable here. Blocks stored
+// without a journal keep the ciphertext they were exported with, which only
+// this node can read if it wrote them, so archives from other nodes must
+// carry every journal.
+func (s *archiveService) Import(ctx context.Context, a *archive.Archive, pub ed25519.PublicKey) (*ImportResult, error) {
+	if pub == nil {
+		pub = s.pubKey
+	}
+	own := pub.Equal(ed25519.PublicKey(s.pubKey))
+
+	if err := a.Verify(pub); err != nil {
+		return nil, apperr.Wrap(apperr.CodeValidation, "archive failed verification: "+err.Error(), err)
+	}
+
+	contractID := a.Contract.ArtifactHash
+	if _, err := s.db.GetContractByID(ctx, contractID); err == nil {
+		return nil, apperr.New(apperr.CodeConflict, "contract already exists")
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	for _, block := range a.Blocks {
+		journal, err := a.Journal(block)
+		if err != nil {
+			return nil, err
+		}
+		if journal == nil {
+			if blocks.IsGenesis(block) {
+				continue
+			}
+			if !own {
+				return nil, apperr.New(apperr.CodeValidation, fmt.Sprintf("archive has no journal for block #%d; export it with journals to import it on another node", block.BlockIndex))
+			}
+			if _, err := keys.DecryptJournal(block.Journal, s.privKey); err != nil {
+				return nil, apperr.Wrap(apperr.CodeValidation, fmt.Sprintf("journal of block #%d is not readable by this node", block.BlockIndex), err)
+			}
+			continue
+		}
+
+		if block.Journal, err = keys.EncryptJournal(journal, s.privKey); err != nil {
+			return nil, err
+		}
+	}
+
+	head := a.Blocks[len(a.Blocks)-1]
+	signedHead := blocks.NewHead(head, s.privKey, time.Now().UTC().UnixMilli())
+
+	if err := s.db.ImportContract(ctx, &a.Contract, &a.Agent, &a.Artifact, a.Blocks, signedHead); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("Contract imported", "contract_id", contractID, "blocks", len(a.Blocks), "head_hash", head.Hash)
+
+	return &ImportResult{
+		ContractID: contractID,
+		Blocks:     len(a.Blocks),
+		HeadIndex:  head.BlockIndex,
+		HeadHash:   head.Hash,
+	}, nil
+}
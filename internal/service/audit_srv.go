@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/peiblow/eeapi/internal/audit"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+const auditPageSize = 1000
+
+type AuditService interface {
+	ListEvents(ctx context.Context, filter schema.AuditFilter) ([]*schema.AuditEvent, error)
+	Verify(ctx context.Context) (*AuditVerification, error)
+}
+
+// AuditVerification is the result of walking the whole audit chain.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Events   int64  `json:"events"`
+	HeadSeq  int64  `json:"head_seq"`
+	HeadHash string `json:"head_hash"`
+	Error    string `json:"error,omitempty"`
+}
+
+type auditService struct {
+	db repository.AuditRepository
+}
+
+func NewAuditService(db *postgres.DB) AuditService {
+	return &auditService{
+		db: repository.NewPsqlAuditRepository(db),
+	}
+}
+
+func (s *auditService) ListEvents(ctx context.Context, filter schema.AuditFilter) ([]*schema.AuditEvent, error) {
+	return s.db.ListEvents(ctx, filter)
+}
+
+func (s *auditService) Verify(ctx context.Context) (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true, HeadHash: audit.GenesisHash}
+
+	for {
+		events, err := s.db.ListEvents(ctx, schema.AuditFilter{AfterSeq: result.HeadSeq, Limit: auditPageSize})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := audit.Verify(events, result.HeadSeq, result.HeadHash); err != nil {
+			if !errors.Is(err, audit.ErrBrokenChain) {
+				return nil, err
+			}
+			result.Valid = false
+			result.Error = err.Error()
+			return result, nil
+		}
+
+		for _, e := range events {
+			result.Events++
+			result.HeadSeq, result.HeadHash = e.Seq, e.Hash
+		}
+
+		if len(events) < auditPageSize {
+			return result, nil
+		}
+	}
+}
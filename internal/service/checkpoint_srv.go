@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/checkpoint"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/merkle"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type CheckpointService interface {
+	Create(ctx context.Context) (*schema.Checkpoint, error)
+	Latest(ctx context.Context) (*schema.Checkpoint, error)
+	Get(ctx context.Context, seq int64) (*schema.Checkpoint, error)
+	ProveBlock(ctx context.Context, contractID string, blockIndex int64) (*CheckpointProof, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// CheckpointProof links a block to a signed checkpoint: the hash chain from
+// the block up to the contract head that the checkpoint covers, and the
+// Merkle path from that head to the checkpoint root.
+type CheckpointProof struct {
+	Checkpoint *schema.Checkpoint    `json:"checkpoint"`
+	Head       schema.CheckpointHead `json:"head"`
+	HeadProof  []merkle.Step         `json:"head_proof"`
+	Chain      []ChainLink           `json:"chain"`
+}
+
+type ChainLink struct {
+	BlockIndex   int64  `json:"block_index"`
+	Hash         string `json:"hash"`
+	PreviousHash string `json:"previous_hash"`
+}
+
+type checkpointService struct {
+	db        repository.CheckpointRepository
+	blockDB   repository.BlockRepository
+	privKey   []byte
+	exporters []checkpoint.Exporter
+}
+
+func NewCheckpointService(db *postgres.DB, privKey []byte, exporters ...checkpoint.Exporter) CheckpointService {
+	return &checkpointService{
+		db:        repository.NewPsqlCheckpointRepository(db),
+		blockDB:   repository.NewPsqlBlockRepository(db),
+		privKey:   privKey,
+		exporters: exporters,
+	}
+}
+
+// Create takes a checkpoint over the current head of every contract chain.
+// It returns nil when nothing changed since the previous checkpoint.
+func (s *checkpointService) Create(ctx context.Context) (*schema.Checkpoint, error) {
+	heads, err := s.db.ListContractHeads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(heads) == 0 {
+		return nil, nil
+	}
+
+	previous, err := s.db.GetLatestCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &schema.Checkpoint{
+		Seq:          1,
+		PreviousHash: "0",
+		CreatedAt:    time.Now().UTC().UnixMilli(),
+		Heads:        heads,
+	}
+	if previous != nil {
+		cp.Seq = previous.Seq + 1
+		cp.PreviousHash = previous.Hash
+	}
+
+	checkpoint.Seal(cp, s.privKey)
+
+	if previous != nil && previous.Root == cp.Root {
+		return nil, nil
+	}
+
+	if err := s.db.SaveCheckpoint(ctx, cp); err != nil {
+		return nil, err
+	}
+	slog.Info("Checkpoint created", "seq", cp.Seq, "root", cp.Root, "heads", cp.HeadCount)
+
+	for _, exporter := range s.exporters {
+		if err := exporter.Export(ctx, cp); err != nil {
+			slog.Error("Failed to export checkpoint", "seq", cp.Seq, "error", err)
+		}
+	}
+
+	return cp, nil
+}
+
+func (s *checkpointService) Latest(ctx context.Context) (*schema.Checkpoint, error) {
+	cp, err := s.db.GetLatestCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cp == nil {
+		return nil, apperr.New(apperr.CodeNotFound, "no checkpoint has been taken yet")
+	}
+
+	return cp, nil
+}
+
+func (s *checkpointService) Get(ctx context.Context, seq int64) (*schema.Checkpoint, error) {
+	cp, err := s.db.GetCheckpoint(ctx, seq)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.CodeNotFound, "checkpoint not found", err)
+		}
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+func (s *checkpointService) ProveBlock(ctx context.Context, contractID string, blockIndex int64) (*CheckpointProof, error) {
+	cp, err := s.db.FindCoveringCheckpoint(ctx, contractID, blockIndex)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.CodeNotFound, "block is not covered by a checkpoint yet", err)
+		}
+		return nil, err
+	}
+
+	var head schema.CheckpointHead
+	for _, h := range cp.Heads {
+		if h.ContractID == contractID {
+			head = h
+			break
+		}
+	}
+
+	headProof, err := merkle.Proof(checkpoint.Leaves(cp.Heads), head.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(head.BlockIndex - blockIndex + 1)
+	segment, err := s.blockDB.ListContractBlocks(ctx, contractID, blockIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segment) != count || segment[len(segment)-1].Hash != head.BlockHash {
+		return nil, apperr.New(apperr.CodeConflict, "stored chain no longer matches the checkpoint")
+	}
+
+	chain := make([]ChainLink, len(segment))
+	for i, block := range segment {
+		chain[i] = ChainLink{
+			BlockIndex:   block.BlockIndex,
+			Hash:         block.Hash,
+			PreviousHash: block.PreviousHash,
+		}
+	}
+
+	// The heads of other contracts are only needed to rebuild the root,
+	// which the proof already covers.
+	header := *cp
+	header.Heads = nil
+
+	return &CheckpointProof{
+		Checkpoint: &header,
+		Head:       head,
+		HeadProof:  headProof,
+		Chain:      chain,
+	}, nil
+}
+
+// Run takes a checkpoint every interval until ctx is done.
+func (s *checkpointService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Create(ctx); err != nil {
+				slog.Error("Failed to create checkpoint", "error", err)
+			}
+		}
+	}
+}
@@ -4,44 +4,104 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/auth"
 	"github.com/peiblow/eeapi/internal/blocks"
 	"github.com/peiblow/eeapi/internal/config"
 	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
 	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/merkle"
+	"github.com/peiblow/eeapi/internal/metrics"
+	"github.com/peiblow/eeapi/internal/receipt"
 	"github.com/peiblow/eeapi/internal/repository"
 	"github.com/peiblow/eeapi/internal/schema"
 	"github.com/peiblow/eeapi/internal/swp"
+	"github.com/peiblow/eeapi/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ContractService interface {
-	DeployContract(ctx context.Context, payload *swp.DeployPayload) (*swp.WireResponse, error)
-	ExecuteContract(ctx context.Context, contractID string, payload *swp.ExecPayload) (*swp.WireResponse, error)
+	DeployContract(ctx context.Context, payload *swp.DeployPayload) (*DeployResult, error)
+	ExecuteContract(ctx context.Context, contractID string, payload *swp.ExecPayload) (*ExecutionResult, error)
+	GetReceipt(ctx context.Context, blockHash string) (*receipt.Receipt, error)
+	ListBlocks(ctx context.Context, contractID string, afterIndex int64, limit int) ([]*schema.Block, error)
+	GetJournalProof(ctx context.Context, contractID string, blockIndex int64, entry int) (*JournalProof, error)
+}
+
+// JournalProof proves that one journal entry is committed to by a block. It
+// carries the block header so the block hash, and with it the signature, can
+// be checked as well.
+type JournalProof struct {
+	ContractID     string          `json:"contract_id"`
+	BlockIndex     int64           `json:"block_index"`
+	BlockHash      string          `json:"block_hash"`
+	BlockSignature string          `json:"block_signature"`
+	PreviousHash   string          `json:"previous_hash"`
+	JournalHash    string          `json:"journal_hash"`
+	JournalRoot    string          `json:"journal_root"`
+	ArtifactHash   string          `json:"artifact_hash"`
+	ArgsHash       string          `json:"args_hash"`
+	HashVersion    int             `json:"hash_version"`
+	Function       string          `json:"function"`
+	Timestamp      int64           `json:"timestamp"`
+	EntryIndex     int             `json:"entry_index"`
+	EntryCount     int             `json:"entry_count"`
+	Entry          json.RawMessage `json:"entry"`
+	LeafHash       string          `json:"leaf_hash"`
+	Proof          []merkle.Step   `json:"proof"`
+}
+
+// DeployResult is the VM's answer to a deployment together with the ID the
+// contract is stored and executed under.
+type DeployResult struct {
+	ContractID string
+	Deploy     swp.DeployResponse
+}
+
+// ExecutionResult is the outcome of a contract execution together with the
+// block that recorded it.
+type ExecutionResult struct {
+	RequestID string           `json:"request_id"`
+	Exec      swp.ExecResponse `json:"exec"`
+	Block     *schema.Block    `json:"block"`
 }
 
 type contractService struct {
 	swpClient *swp.SwpClient
 	db        repository.ContractRepository
 	blockDB   repository.BlockRepository
+	heads     repository.HeadRepository
+	outbox    repository.OutboxRepository
 	privKey   []byte
 	pubKey    []byte
 	locker    *config.ContractLocker
+	publisher events.Publisher
+	quotas    QuotaService
 }
 
-func NewContractService(swpClient *swp.SwpClient, db *postgres.DB, privKey []byte, pubKey []byte, locker *config.ContractLocker) ContractService {
+func NewContractService(swpClient *swp.SwpClient, db *postgres.DB, privKey []byte, pubKey []byte, locker *config.ContractLocker, publisher events.Publisher, quotas QuotaService) ContractService {
 	return &contractService{
 		swpClient: swpClient,
 		db:        repository.NewPsqlContractRepository(db),
 		blockDB:   repository.NewPsqlBlockRepository(db),
+		heads:     repository.NewPsqlHeadRepository(db),
+		outbox:    repository.NewPsqlOutboxRepository(db),
 		privKey:   privKey,
 		pubKey:    pubKey,
 		locker:    locker,
+		publisher: publisher,
+		quotas:    quotas,
 	}
 }
 
@@ -53,13 +113,21 @@ type ArtifactMetadata struct {
 	InitStorage  map[string]interface{} `json:"init_storage"`
 }
 
-func (s *contractService) DeployContract(ctx context.Context, payload *swp.DeployPayload) (*swp.WireResponse, error) {
+func (s *contractService) DeployContract(ctx context.Context, payload *swp.DeployPayload) (_ *DeployResult, err error) {
+	ctx, span := tracing.Start(ctx, "ContractService.DeployContract", trace.WithAttributes(
+		attribute.String("contract.name", payload.ContractName),
+		attribute.String("contract.owner", payload.Owner),
+	))
+	defer func() { tracing.End(span, err) }()
 
 	createdAt := time.Now().UTC()
 	hashInput := fmt.Sprintf("%v:%v:%v:%v", payload.Owner, payload.ContractName, payload.Version, createdAt.UnixMilli())
 	hashBytes := sha256.Sum256([]byte(hashInput))
 	hash := "0x" + hex.EncodeToString(hashBytes[:])
 
+	ctx = logging.With(ctx, "contract_id", hash)
+	logger := logging.FromContext(ctx)
+
 	msg := swp.WireMesage{
 		Type: swp.DEPLOY,
 		ID:   uuid.New().String(),
@@ -73,12 +141,12 @@ func (s *contractService) DeployContract(ctx context.Context, payload *swp.Deplo
 	}
 
 	var resp swp.WireResponse
-	if err := s.swpClient.Send(msg, &resp); err != nil {
-		return nil, err
+	if err := s.swpClient.Send(ctx, msg, &resp); err != nil {
+		return nil, apperr.Wrap(apperr.CodeVMUnavailable, "virtual machine unavailable", err)
 	}
 
 	if resp.Success == false {
-		return &resp, fmt.Errorf("contract deployment failed: %s", string(resp.Error))
+		return nil, apperr.New(apperr.CodeVMRejected, "contract deployment rejected: "+resp.Error)
 	}
 
 	var respData swp.DeployResponse
@@ -93,39 +161,103 @@ func (s *contractService) DeployContract(ctx context.Context, payload *swp.Deplo
 	}); err != nil {
 		return nil, err
 	}
-	slog.Info("Agent meta saved successfully", "agent_hash", respData.Agent.Hash)
+	logger.Info("Agent meta saved successfully", "agent_hash", respData.Agent.Hash)
 
 	if err := s.db.SaveContractArtifact(ctx, hash, respData.Agent.Hash, &respData.ContractArtifact); err != nil {
 		return nil, err
 	}
-	slog.Info("Contract artifact saved successfully", "contract_hash", respData.ContractHash)
+	logger.Info("Contract artifact saved successfully", "contract_hash", respData.ContractHash)
 
 	if err := s.db.SaveContract(ctx, &schema.Contract{
 		Name:         respData.ContractName,
 		Owner:        respData.ContractOwner,
 		ArtifactHash: hash,
 		CreatedAt:    createdAt.UnixMilli(),
+		MaxPrice:     payload.MaxPrice,
 	}); err != nil {
 		return nil, err
 	}
-	slog.Info("Contract deployed successfully", "contract_hash", respData.ContractHash)
+	logger.Info("Contract deployed successfully", "contract_hash", respData.ContractHash)
+
+	event, err := events.New(events.ContractDeployed, hash, "", events.DeployData{
+		ContractName:    respData.ContractName,
+		ContractOwner:   respData.ContractOwner,
+		ContractVersion: respData.ContractVersion,
+		AgentHash:       respData.Agent.Hash,
+	})
+	if err == nil {
+		err = s.outbox.Enqueue(ctx, event)
+	}
+	if err != nil {
+		logger.Error("Failed to enqueue deploy event", "error", err)
+	}
 
-	return &resp, nil
+	return &DeployResult{ContractID: hash, Deploy: respData}, nil
 }
 
-func (s *contractService) ExecuteContract(ctx context.Context, contractID string, payload *swp.ExecPayload) (*swp.WireResponse, error) {
+func (s *contractService) ExecuteContract(ctx context.Context, contractID string, payload *swp.ExecPayload) (_ *ExecutionResult, err error) {
+	ctx, span := tracing.Start(ctx, "ContractService.ExecuteContract", trace.WithAttributes(
+		attribute.String("contract.id", contractID),
+		attribute.String("contract.function", payload.Function),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	ctx = logging.With(ctx, "contract_id", contractID, "function", payload.Function)
+	logger := logging.FromContext(ctx)
+
+	if payload.MaxPrice < 0 {
+		return nil, apperr.New(apperr.CodeValidation, "max_price must not be negative")
+	}
+
 	s.locker.Lock(contractID)
 	defer s.locker.Unlock(contractID)
 
-	slog.Info("Executing contract", "contract_id", contractID, "function", payload.Function)
+	logger.Info("Executing contract")
 	timestamp := time.Now().UTC().UnixMilli()
 
 	contract, err := s.db.GetContractByID(ctx, contractID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.CodeNotFound, "contract not found", err)
+		}
 		return nil, err
 	}
 
-	slog.Info("Retrieving contract artifact", "artifact_hash", contract.ArtifactHash)
+	// A frozen contract must not cost VM time or quota. The watchdog freezes
+	// under the contract lock, so the check holds until the block is saved.
+	head, err := s.heads.GetHead(ctx, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if head != nil && head.Frozen {
+		return nil, apperr.New(apperr.CodeFrozen, "contract is frozen: "+head.FrozenReason)
+	}
+
+	identity := auth.Identity(ctx)
+	release, remaining, err := s.quotas.Acquire(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Once the VM has been asked to run the execution it may have done the
+	// work, so only failures before that give the execution back.
+	refund := true
+	defer func() {
+		if err != nil && refund {
+			release()
+		}
+	}()
+
+	maxPrice := payload.MaxPrice
+	if maxPrice == 0 {
+		maxPrice = contract.MaxPrice
+	}
+	// An execution may not cost more than is left of the caller's budget.
+	if remaining > 0 && (maxPrice == 0 || maxPrice > remaining) {
+		maxPrice = remaining
+	}
+
+	logger.Info("Retrieving contract artifact", "artifact_hash", contract.ArtifactHash)
 	artifact, err := s.db.GetContractArtifactByHash(ctx, contract.ArtifactHash)
 	if err != nil {
 		return nil, err
@@ -139,16 +271,19 @@ func (s *contractService) ExecuteContract(ctx context.Context, contractID string
 			ArtifactHash:     contract.ArtifactHash,
 			Function:         payload.Function,
 			Args:             payload.Args,
+			MaxPrice:         maxPrice,
 		},
 	}
 
+	refund = false
+
 	var resp swp.WireResponse
-	if err := s.swpClient.Send(msg, &resp); err != nil {
-		return nil, err
+	if err := s.swpClient.Send(ctx, msg, &resp); err != nil {
+		return nil, apperr.Wrap(apperr.CodeVMUnavailable, "virtual machine unavailable", err)
 	}
 
 	if resp.Success == false {
-		return &resp, fmt.Errorf("contract execution failed: %s", string(resp.Error))
+		return nil, apperr.New(apperr.CodeVMRejected, "contract execution rejected: "+resp.Error)
 	}
 
 	var respData swp.ExecResponse
@@ -156,65 +291,193 @@ func (s *contractService) ExecuteContract(ctx context.Context, contractID string
 		return nil, err
 	}
 
+	// The VM is asked to respect the limit too, but the block is only
+	// written if the reported price is within it.
+	if maxPrice > 0 && respData.ExecPrice > maxPrice {
+		logger.Warn("Execution exceeded max price", "exec_price", respData.ExecPrice, "max_price", maxPrice)
+		return nil, apperr.New(apperr.CodePriceExceeded, fmt.Sprintf("execution cost %d exceeds max_price %d", respData.ExecPrice, maxPrice)).
+			WithDetails(map[string]any{
+				"exec_price":       respData.ExecPrice,
+				"max_price":        maxPrice,
+				"budget_remaining": remaining,
+			})
+	}
+
 	previousBlock, err := s.blockDB.GetLastContractBlock(ctx, contractID)
 	if err != nil {
-		slog.Error("Failed to retrieve last block", "error", err)
+		logger.Error("Failed to retrieve last block", "error", err)
+		return nil, err
+	}
+
+	hashVersion := blocks.CurrentHashVersion
+
+	journalBytes, err := blocks.EncodeJournal(hashVersion, respData.Journal)
+	if err != nil {
+		logger.Error("Failed to marshal journal", "error", err)
 		return nil, err
 	}
 
-	journalBytes, err := json.Marshal(respData.Journal)
+	argsHash, err := blocks.ArgsHash(hashVersion, payload.Args)
 	if err != nil {
-		slog.Error("Failed to marshal journal", "error", err)
+		logger.Error("Failed to marshal args", "error", err)
 		return nil, err
 	}
 
-	journalHashRaw := sha256.Sum256(append(journalBytes, []byte(fmt.Sprintf("%d", timestamp))...))
-	journalHash := "0x" + hex.EncodeToString(journalHashRaw[:])
+	journalHash, err := blocks.JournalHash(hashVersion, journalBytes, timestamp)
+	if err != nil {
+		return nil, err
+	}
 
-	blockData := fmt.Sprintf(
-		"%d|%s|%s|%s|%s|%s",
-		timestamp,
-		previousBlock.Hash,
-		journalHash,
-		contractID,
-		payload.Function,
-		respData.ArtifactHash,
-	)
-	blocHashRaw := sha256.Sum256([]byte(blockData))
-	blockHash := "0x" + hex.EncodeToString(blocHashRaw[:])
+	journalRoot, err := blocks.JournalRoot(journalBytes)
+	if err != nil {
+		logger.Error("Failed to compute journal root", "error", err)
+		return nil, err
+	}
 
 	encryptedJournal, err := keys.EncryptJournal(journalBytes, s.privKey)
 	if err != nil {
-		slog.Error("Failed to encrypt journal", "error", err)
+		logger.Error("Failed to encrypt journal", "error", err)
 		return nil, err
 	}
 
-	signature := ed25519.Sign(s.privKey, blocHashRaw[:])
-
-	slog.Info("Saving execution block", "block_hash", blockHash, "previous_hash", previousBlock.Hash, "journal_hash", journalHash, "contract_id", contractID, "function", payload.Function)
 	block := &schema.Block{
 		BlockIndex:   previousBlock.BlockIndex + 1,
-		Hash:         blockHash,
 		Timestamp:    timestamp,
 		PreviousHash: previousBlock.Hash,
 		JournalHash:  journalHash,
-		Signature:    signature,
 		ContractID:   contractID,
 		FunctionName: payload.Function,
+		ArgsHash:     argsHash,
+		JournalRoot:  journalRoot,
+		ArtifactHash: respData.ArtifactHash,
+		HashVersion:  hashVersion,
+		ExecPrice:    respData.ExecPrice,
 		Journal:      encryptedJournal,
 	}
 
+	blockHashRaw, err := blocks.ComputeHash(block)
+	if err != nil {
+		return nil, err
+	}
+	block.Hash = "0x" + hex.EncodeToString(blockHashRaw)
+	block.Signature = ed25519.Sign(s.privKey, blockHashRaw)
+
+	logger.Info("Saving execution block", "block_hash", block.Hash, "previous_hash", previousBlock.Hash, "journal_hash", journalHash)
+
 	if err := blocks.VerifyBlock(*previousBlock, *block, journalBytes, s.pubKey); err != nil {
 		return nil, err
 	}
-	slog.Info("Block verification successful", "block_hash", block.Hash)
+	logger.Info("Block verification successful", "block_hash", block.Hash)
 
-	if err := s.blockDB.SaveBlock(ctx, block); err != nil {
-		slog.Error("Failed to save execution block", "error", err)
+	event, err := events.NewBlockEvent(block)
+	if err != nil {
 		return nil, err
 	}
-	slog.Info("Execution block saved successfully", "block_hash", block.Hash)
 
-	slog.Info("Contract executed successfully", "contract_hash", respData.ArtifactHash, "function", respData.Function, "exec_price", respData.ExecPrice)
-	return &resp, nil
+	newHead := blocks.NewHead(block, s.privKey, time.Now().UTC().UnixMilli())
+
+	usage := &schema.UsageEntry{
+		ContractID:   contractID,
+		Owner:        contract.Owner,
+		Caller:       identity,
+		FunctionName: payload.Function,
+		BlockIndex:   block.BlockIndex,
+		BlockHash:    block.Hash,
+		ExecPrice:    respData.ExecPrice,
+		CreatedAt:    timestamp,
+	}
+
+	if err := s.blockDB.SaveBlock(ctx, block, newHead, usage, event); err != nil {
+		logger.Error("Failed to save execution block", "error", err)
+		return nil, err
+	}
+	logger.Info("Execution block saved successfully", "block_hash", block.Hash)
+	metrics.BlockAppended(contractID, payload.Function, respData.ExecPrice)
+	span.SetAttributes(
+		attribute.Int64("block.index", block.BlockIndex),
+		attribute.String("block.hash", block.Hash),
+		attribute.Int64("exec.price", respData.ExecPrice),
+	)
+
+	// Durable delivery goes through the outbox; live subscribers are told
+	// straight away and resume by block index if they miss anything.
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		logger.Error("Failed to publish block event", "block_hash", block.Hash, "error", err)
+	}
+
+	logger.Info("Contract executed successfully", "exec_price", respData.ExecPrice)
+	return &ExecutionResult{
+		RequestID: resp.ID,
+		Exec:      respData,
+		Block:     block,
+	}, nil
+}
+
+func (s *contractService) GetReceipt(ctx context.Context, blockHash string) (*receipt.Receipt, error) {
+	block, err := s.blockDB.GetBlockByHash(ctx, blockHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.CodeNotFound, "block not found", err)
+		}
+		return nil, err
+	}
+
+	if blocks.IsGenesis(block) {
+		return nil, apperr.New(apperr.CodeNotFound, "genesis blocks have no receipt")
+	}
+
+	return receipt.New(block, s.privKey), nil
+}
+
+func (s *contractService) ListBlocks(ctx context.Context, contractID string, afterIndex int64, limit int) ([]*schema.Block, error) {
+	return s.blockDB.ListContractBlocks(ctx, contractID, afterIndex, limit)
+}
+
+func (s *contractService) GetJournalProof(ctx context.Context, contractID string, blockIndex int64, entry int) (*JournalProof, error) {
+	block, err := s.blockDB.GetContractBlock(ctx, contractID, blockIndex)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.CodeNotFound, "block not found", err)
+		}
+		return nil, err
+	}
+
+	if block.JournalRoot == "" {
+		return nil, apperr.New(apperr.CodeNotFound, "block has no journal commitment")
+	}
+
+	journalBytes, err := keys.DecryptJournal(block.Journal, s.privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := blocks.JournalLeaves(journalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := merkle.Proof(leaves, entry)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.CodeValidation, fmt.Sprintf("entry must be between 0 and %d", len(leaves)-1), err)
+	}
+
+	return &JournalProof{
+		ContractID:     block.ContractID,
+		BlockIndex:     block.BlockIndex,
+		BlockHash:      block.Hash,
+		BlockSignature: "0x" + hex.EncodeToString(block.Signature),
+		PreviousHash:   block.PreviousHash,
+		JournalHash:    block.JournalHash,
+		JournalRoot:    block.JournalRoot,
+		ArtifactHash:   block.ArtifactHash,
+		ArgsHash:       block.ArgsHash,
+		HashVersion:    blocks.Version(block),
+		Function:       block.FunctionName,
+		Timestamp:      block.Timestamp,
+		EntryIndex:     entry,
+		EntryCount:     len(leaves),
+		Entry:          leaves[entry],
+		LeafHash:       "0x" + hex.EncodeToString(merkle.LeafHash(leaves[entry])),
+		Proof:          steps,
+	}, nil
 }
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/config"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/swp"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type HealthService interface {
+	Ready(ctx context.Context) *Readiness
+	// MarkStarted records that startup finished; until then the service is
+	// not ready regardless of its dependencies.
+	MarkStarted()
+	Status(ctx context.Context) *Status
+}
+
+type Readiness struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type CheckResult struct {
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Status struct {
+	StartedAt int64            `json:"started_at"`
+	Uptime    string           `json:"uptime"`
+	SVM       swp.ClientStatus `json:"svm"`
+	Locks     int              `json:"locks"`
+	Build     BuildInfo        `json:"build"`
+	Keys      KeyInfo          `json:"keys"`
+	Runtime   RuntimeInfo      `json:"runtime"`
+}
+
+type BuildInfo struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+type KeyInfo struct {
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type RuntimeInfo struct {
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heap_bytes"`
+}
+
+type healthService struct {
+	db        *postgres.DB
+	svm       *swp.SwpClient
+	locker    *config.ContractLocker
+	pubKey    []byte
+	startedAt time.Time
+	started   atomic.Bool
+}
+
+func NewHealthService(db *postgres.DB, svm *swp.SwpClient, locker *config.ContractLocker, pubKey []byte) HealthService {
+	return &healthService{
+		db:        db,
+		svm:       svm,
+		locker:    locker,
+		pubKey:    pubKey,
+		startedAt: time.Now(),
+	}
+}
+
+func (s *healthService) MarkStarted() {
+	s.started.Store(true)
+}
+
+// Ready pings Postgres, bounded by the readiness timeout, and reports the
+// SVM from the client's background PING: its latency, and an error if the
+// client is disconnected, its breaker is open, or the last ping failed or
+// is stale. Pinging the SVM here would queue behind running executions for
+// the single connection.
+func (s *healthService) Ready(ctx context.Context) *Readiness {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) (time.Duration, error){
+		"postgres": func(ctx context.Context) (time.Duration, error) {
+			start := time.Now()
+			err := s.db.PingContext(ctx)
+			return time.Since(start), err
+		},
+		"svm": func(ctx context.Context) (time.Duration, error) {
+			status := s.svm.Status()
+			latency := time.Duration(status.LastPingMs) * time.Millisecond
+			switch {
+			case !status.Connected:
+				return 0, fmt.Errorf("not connected: %s", status.LastError)
+			case status.Circuit != "closed":
+				return latency, swp.ErrCircuitOpen
+			case status.LastPingAt == 0:
+				return 0, errors.New("no ping yet")
+			case status.PingError != "":
+				return latency, fmt.Errorf("ping failed: %s", status.PingError)
+			case time.Since(time.UnixMilli(status.LastPingAt)) > 3*swp.PingInterval:
+				return latency, errors.New("last ping is stale")
+			}
+			return latency, nil
+		},
+	}
+
+	type named struct {
+		name   string
+		result CheckResult
+	}
+	results := make(chan named, len(checks))
+	for name, check := range checks {
+		go func() {
+			latency, err := check(ctx)
+
+			result := CheckResult{OK: err == nil, LatencyMs: latency.Milliseconds()}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results <- named{name, result}
+		}()
+	}
+
+	readiness := &Readiness{Ready: s.started.Load(), Checks: make(map[string]CheckResult, len(checks)+1)}
+	readiness.Checks["startup"] = CheckResult{OK: readiness.Ready}
+	if !readiness.Ready {
+		readiness.Checks["startup"] = CheckResult{Error: "waiting for dependencies"}
+	}
+	for range checks {
+		select {
+		case r := <-results:
+			readiness.Checks[r.name] = r.result
+			readiness.Ready = readiness.Ready && r.result.OK
+		case <-ctx.Done():
+			// A check can outlive its context, e.g. a Postgres ping on a
+			// connection that stopped answering.
+			for name := range checks {
+				if _, ok := readiness.Checks[name]; !ok {
+					readiness.Checks[name] = CheckResult{LatencyMs: readinessTimeout.Milliseconds(), Error: ctx.Err().Error()}
+				}
+			}
+			readiness.Ready = false
+			return readiness
+		}
+	}
+
+	return readiness
+}
+
+func (s *healthService) Status(ctx context.Context) *Status {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fingerprint := sha256.Sum256(s.pubKey)
+
+	return &Status{
+		StartedAt: s.startedAt.UTC().UnixMilli(),
+		Uptime:    time.Since(s.startedAt).Round(time.Second).String(),
+		SVM:       s.svm.Status(),
+		Locks:     s.locker.Len(),
+		Build:     buildInfo(),
+		Keys: KeyInfo{
+			PublicKey:   "0x" + hex.EncodeToString(ed25519.PublicKey(s.pubKey)),
+			Fingerprint: "SHA256:" + hex.EncodeToString(fingerprint[:]),
+		},
+		Runtime: RuntimeInfo{
+			Goroutines: runtime.NumGoroutine(),
+			HeapBytes:  mem.HeapAlloc,
+		},
+	}
+}
+
+func buildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Module = bi.Main.Path
+	info.Version = bi.Main.Version
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.Time = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/auth"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+	"github.com/peiblow/eeapi/internal/swp"
+)
+
+const (
+	jobQueueSize = 256
+
+	// jobLease is how long a claimed job may go without its worker renewing
+	// the lease before the sweep considers the worker dead. Workers renew it
+	// every jobHeartbeat while the job runs.
+	jobLease         = 2 * time.Minute
+	jobHeartbeat     = 30 * time.Second
+	jobSweepInterval = 30 * time.Second
+
+	jobInterruptedCode = "JOB_INTERRUPTED"
+)
+
+type JobService interface {
+	Enqueue(ctx context.Context, contractID string, payload *swp.ExecPayload) (*schema.Job, error)
+	GetJob(ctx context.Context, id string) (*schema.Job, error)
+	JobResult(job *schema.Job) (*ExecutionResult, error)
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// jobService runs executions in the background. Jobs are sharded across
+// workers by contract ID so each contract's jobs run in the order they were
+// enqueued; the ContractLocker still serializes them against synchronous
+// executions of the same contract.
+type jobService struct {
+	contracts ContractService
+	db        repository.JobRepository
+	privKey   []byte
+
+	queues []chan string
+	wg     sync.WaitGroup
+
+	mu        sync.RWMutex
+	running   bool
+	cancel    context.CancelFunc
+	stopSweep context.CancelFunc
+}
+
+func NewJobService(contracts ContractService, db *postgres.DB, privKey []byte, workers int) JobService {
+	if workers < 1 {
+		workers = 1
+	}
+
+	queues := make([]chan string, workers)
+	for i := range queues {
+		queues[i] = make(chan string, jobQueueSize)
+	}
+
+	return &jobService{
+		contracts: contracts,
+		db:        repository.NewPsqlJobRepository(db),
+		privKey:   privKey,
+		queues:    queues,
+	}
+}
+
+func (s *jobService) Enqueue(ctx context.Context, contractID string, payload *swp.ExecPayload) (*schema.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.running {
+		return nil, apperr.New(apperr.CodeVMUnavailable, "job workers are not running")
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	job := &schema.Job{
+		ID:           uuid.New().String(),
+		ContractID:   contractID,
+		FunctionName: payload.Function,
+		Identity:     auth.Identity(ctx),
+		Payload:      payloadJSON,
+		Status:       schema.JobQueued,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := s.db.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	if !s.dispatch(job) {
+		job.Status = schema.JobFailed
+		job.ErrorCode = string(apperr.CodeVMUnavailable)
+		job.Error = "job queue is full"
+		job.UpdatedAt = time.Now().UTC().UnixMilli()
+		if err := s.db.CompleteJob(ctx, job); err != nil {
+			logging.FromContext(ctx).Error("Failed to mark job as failed", "job_id", job.ID, "error", err)
+		}
+		return nil, apperr.New(apperr.CodeVMUnavailable, "job queue is full")
+	}
+
+	logging.FromContext(ctx).Info("Execution job enqueued", "job_id", job.ID, "contract_id", contractID, "function", payload.Function)
+	return job, nil
+}
+
+func (s *jobService) GetJob(ctx context.Context, id string) (*schema.Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, apperr.New(apperr.CodeNotFound, "job not found")
+	}
+
+	job, err := s.db.GetJob(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperr.Wrap(apperr.CodeNotFound, "job not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Results carry the plaintext journal, so only the caller that enqueued
+	// the job may see it. Other callers are told it does not exist.
+	if job.Identity != auth.Identity(ctx) {
+		return nil, apperr.New(apperr.CodeNotFound, "job not found")
+	}
+
+	return job, nil
+}
+
+// JobResult decrypts the execution result stored on a succeeded job.
+func (s *jobService) JobResult(job *schema.Job) (*ExecutionResult, error) {
+	if job.Status != schema.JobSucceeded || len(job.Result) == 0 {
+		return nil, nil
+	}
+
+	plain, err := keys.DecryptJournal(job.Result, s.privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ExecutionResult
+	if err := json.Unmarshal(plain, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Start launches the workers and a sweep that hands them jobs still queued
+// in the database, from a previous run, another replica or a full queue.
+// Jobs are claimed atomically, so a job runs at most once across replicas.
+func (s *jobService) Start(ctx context.Context) error {
+	if err := s.sweep(ctx); err != nil {
+		return err
+	}
+
+	workerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	sweepCtx, stopSweep := context.WithCancel(workerCtx)
+
+	s.mu.Lock()
+	s.running = true
+	s.cancel = cancel
+	s.stopSweep = stopSweep
+	s.mu.Unlock()
+
+	for i, queue := range s.queues {
+		s.wg.Add(1)
+		go s.work(workerCtx, i, queue)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(jobSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.sweep(sweepCtx); err != nil && sweepCtx.Err() == nil {
+				slog.Error("Job sweep failed", "error", err)
+			}
+
+			select {
+			case <-sweepCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// sweep fails jobs whose lease expired and dispatches queued jobs nobody has
+// claimed. Jobs that do not fit in the queues stay queued for the next sweep.
+func (s *jobService) sweep(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	failed, err := s.db.FailExpiredJobs(ctx, now.UnixMilli(), jobInterruptedCode, "execution was interrupted; check the contract's blocks before retrying")
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		slog.Warn("Failed interrupted execution jobs", "count", failed)
+	}
+
+	queued, err := s.db.ListQueuedJobs(ctx, now.Add(-jobSweepInterval).UnixMilli(), jobQueueSize*len(s.queues))
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.running {
+		return nil
+	}
+
+	dispatched := 0
+	for _, job := range queued {
+		if s.dispatch(job) {
+			dispatched++
+		}
+	}
+	if dispatched > 0 {
+		slog.Info("Dispatched queued execution jobs", "count", dispatched, "queued", len(queued))
+	}
+
+	return nil
+}
+
+// Stop stops accepting jobs and waits for the workers to finish the job they
+// are running. Jobs still queued are picked up again on the next Start.
+func (s *jobService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	s.stopSweep()
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return errors.New("timed out waiting for job workers")
+	}
+}
+
+func (s *jobService) dispatch(job *schema.Job) bool {
+	h := fnv.New32a()
+	h.Write([]byte(job.ContractID))
+	queue := s.queues[h.Sum32()%uint32(len(s.queues))]
+
+	select {
+	case queue <- job.ID:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *jobService) work(ctx context.Context, worker int, queue chan string) {
+	defer s.wg.Done()
+
+	for id := range queue {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		s.process(ctx, worker, id)
+	}
+}
+
+func (s *jobService) process(ctx context.Context, worker int, id string) {
+	ctx = logging.With(ctx, "job_id", id, "worker", worker)
+	logger := logging.FromContext(ctx)
+
+	job, err := s.db.ClaimJob(ctx, id, time.Now().Add(jobLease).UTC().UnixMilli())
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Debug("Job already claimed")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to claim job", "error", err)
+		return
+	}
+	logger.Info("Running execution job", "contract_id", job.ContractID)
+
+	stopHeartbeat := s.heartbeat(ctx, id)
+	job.Status = schema.JobFailed
+	err = s.run(auth.WithIdentity(ctx, job.Identity), job)
+	stopHeartbeat()
+
+	if err != nil {
+		e := apperr.From(err)
+		job.ErrorCode = string(e.Code)
+		job.Error = e.Message
+		logger.Error("Execution job failed", "error", err)
+	} else {
+		job.Status = schema.JobSucceeded
+	}
+	job.UpdatedAt = time.Now().UTC().UnixMilli()
+
+	if err := s.db.CompleteJob(context.WithoutCancel(ctx), job); err != nil {
+		logger.Error("Failed to store job result", "error", err)
+	}
+}
+
+// heartbeat renews the lease of a running job every jobHeartbeat until the
+// returned function is called, so a long execution is not failed by the
+// sweep while its worker is alive.
+func (s *jobService) heartbeat(ctx context.Context, id string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(jobHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.db.ExtendJob(ctx, id, time.Now().Add(jobLease).UTC().UnixMilli()); err != nil && ctx.Err() == nil {
+					logging.FromContext(ctx).Error("Failed to renew job lease", "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *jobService) run(ctx context.Context, job *schema.Job) error {
+	var payload swp.ExecPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return apperr.Wrap(apperr.CodeValidation, "invalid job payload", err)
+	}
+
+	result, err := s.contracts.ExecuteContract(ctx, job.ContractID, &payload)
+	if err != nil {
+		return err
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	// Results carry the plaintext journal, so they are stored encrypted just
+	// like the journal on the block itself.
+	encrypted, err := keys.EncryptJournal(resultJSON, s.privKey)
+	if err != nil {
+		return err
+	}
+
+	job.Result = encrypted
+	job.BlockIndex = result.Block.BlockIndex
+	job.BlockHash = result.Block.Hash
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/config"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type QuotaService interface {
+	// Acquire counts an execution against the identity's daily quota and
+	// checks its ExecPrice budget. The returned release gives the execution
+	// back when it failed before reaching the VM, and remaining is the
+	// ExecPrice the execution may cost at most, or 0 without a budget. The
+	// ExecPrice of a block is charged when the block is saved.
+	Acquire(ctx context.Context, identity string) (release func(), remaining int64, err error)
+	Usage(ctx context.Context, identity string) (*schema.QuotaUsage, error)
+}
+
+type quotaService struct {
+	db       repository.QuotaRepository
+	defaults config.QuotaConfig
+}
+
+func NewQuotaService(db *postgres.DB, defaults config.QuotaConfig) QuotaService {
+	return &quotaService{
+		db:       repository.NewPsqlQuotaRepository(db),
+		defaults: defaults,
+	}
+}
+
+func (s *quotaService) Acquire(ctx context.Context, identity string) (func(), int64, error) {
+	quota, err := s.db.GetQuota(ctx, identity)
+	if err != nil {
+		return nil, 0, err
+	}
+	daily, budget := s.limits(quota)
+
+	var remaining int64
+	if budget > 0 {
+		remaining = budget
+		if quota != nil {
+			remaining -= quota.ExecPriceSpent
+		}
+		if remaining <= 0 {
+			return nil, 0, apperr.New(apperr.CodeQuotaExceeded, fmt.Sprintf("ExecPrice budget of %d exhausted", budget))
+		}
+	}
+
+	day := today()
+	counted, err := s.db.CountExecution(ctx, identity, day, daily)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !counted {
+		return nil, 0, apperr.New(apperr.CodeQuotaExceeded, fmt.Sprintf("daily quota of %d executions reached", daily))
+	}
+
+	release := func() {
+		if err := s.db.UncountExecution(context.WithoutCancel(ctx), identity, day); err != nil {
+			logging.FromContext(ctx).Error("Failed to release execution quota", "error", err)
+		}
+	}
+
+	return release, remaining, nil
+}
+
+func (s *quotaService) Usage(ctx context.Context, identity string) (*schema.QuotaUsage, error) {
+	quota, err := s.db.GetQuota(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	day := today()
+	executions, err := s.db.GetExecutions(ctx, identity, day)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &schema.QuotaUsage{
+		Identity:   identity,
+		Day:        day,
+		Executions: executions,
+	}
+	usage.DailyExecutions, usage.ExecPriceBudget = s.limits(quota)
+	if quota != nil {
+		usage.ExecPriceSpent = quota.ExecPriceSpent
+	}
+
+	return usage, nil
+}
+
+// limits resolves the effective limits of a stored quota, which may be nil.
+func (s *quotaService) limits(quota *schema.Quota) (daily int64, budget int64) {
+	daily, budget = s.defaults.DailyExecutions, s.defaults.ExecPriceBudget
+	if quota == nil {
+		return daily, budget
+	}
+
+	if quota.DailyExecutions != nil {
+		daily = *quota.DailyExecutions
+	}
+	if quota.ExecPriceBudget != nil {
+		budget = *quota.ExecPriceBudget
+	}
+
+	return daily, budget
+}
+
+// today is the UTC day executions are counted against.
+func today() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
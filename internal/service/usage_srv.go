@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type UsageService interface {
+	Usage(ctx context.Context, from int64, to int64, groupBy schema.UsageGroup) (*UsageReport, error)
+}
+
+// UsageReport is the VM usage billed between From (inclusive) and To
+// (exclusive), both in unix milliseconds.
+type UsageReport struct {
+	From    int64              `json:"from"`
+	To      int64              `json:"to"`
+	GroupBy schema.UsageGroup  `json:"group_by"`
+	Rows    []*schema.UsageRow `json:"rows"`
+	Total   UsageTotal         `json:"total"`
+}
+
+type UsageTotal struct {
+	Executions int64 `json:"executions"`
+	ExecPrice  int64 `json:"exec_price"`
+}
+
+type usageService struct {
+	db repository.UsageRepository
+}
+
+func NewUsageService(db *postgres.DB) UsageService {
+	return &usageService{
+		db: repository.NewPsqlUsageRepository(db),
+	}
+}
+
+func (s *usageService) Usage(ctx context.Context, from int64, to int64, groupBy schema.UsageGroup) (*UsageReport, error) {
+	switch groupBy {
+	case schema.UsageByContract, schema.UsageByFunction, schema.UsageByCaller, schema.UsageByOwner:
+	default:
+		return nil, apperr.New(apperr.CodeValidation, "group_by must be one of contract, function, caller or owner")
+	}
+
+	if from >= to {
+		return nil, apperr.New(apperr.CodeValidation, "from must be before to")
+	}
+
+	rows, err := s.db.Aggregate(ctx, from, to, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UsageReport{
+		From:    from,
+		To:      to,
+		GroupBy: groupBy,
+		Rows:    rows,
+	}
+	if report.Rows == nil {
+		report.Rows = []*schema.UsageRow{}
+	}
+	for _, row := range rows {
+		report.Total.Executions += row.Executions
+		report.Total.ExecPrice += row.ExecPrice
+	}
+
+	return report, nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/blocks"
+	"github.com/peiblow/eeapi/internal/config"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/keys"
+	"github.com/peiblow/eeapi/internal/metrics"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+type WatchdogService interface {
+	Check(ctx context.Context, contractID string) error
+	CheckAll(ctx context.Context) error
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type watchdogService struct {
+	heads   repository.HeadRepository
+	blockDB repository.BlockRepository
+	outbox  repository.OutboxRepository
+	locker  *config.ContractLocker
+	privKey []byte
+	pubKey  []byte
+}
+
+func NewWatchdogService(db *postgres.DB, privKey []byte, pubKey []byte, locker *config.ContractLocker) WatchdogService {
+	return &watchdogService{
+		heads:   repository.NewPsqlHeadRepository(db),
+		blockDB: repository.NewPsqlBlockRepository(db),
+		outbox:  repository.NewPsqlOutboxRepository(db),
+		locker:  locker,
+		privKey: privKey,
+		pubKey:  pubKey,
+	}
+}
+
+// Check recomputes a contract's chain up to its head and compares the head
+// with the signed head record. On mismatch the contract is frozen and an
+// alert is raised. Contracts without a head record, written before heads
+// were recorded, get one signed over their current chain once every block's
+// signature and journal verifies with this node's key.
+//
+// The chain is read and verified without holding the contract lock, so
+// executions are not held up by the check. Executions may move the head
+// meanwhile, so before acting on a finding Check takes the lock and re-reads
+// the head, and reads the contract again if it moved.
+func (s *watchdogService) Check(ctx context.Context, contractID string) error {
+	for attempt := 1; ; attempt++ {
+		moved, err := s.check(ctx, contractID, attempt == watchdogAttempts)
+		if err != nil || !moved {
+			return err
+		}
+	}
+}
+
+// watchdogAttempts is how many times Check reads a contract whose head keeps
+// moving before it judges the last read.
+const watchdogAttempts = 3
+
+// check verifies the chain once. If it does not match the head record and
+// the record moved since it was read, check reports moved instead of acting
+// on it, unless final is set.
+func (s *watchdogService) check(ctx context.Context, contractID string, final bool) (moved bool, err error) {
+	head, err := s.heads.GetHead(ctx, contractID)
+	if err != nil {
+		return false, err
+	}
+
+	if head != nil && head.Frozen {
+		return false, nil
+	}
+
+	chain, err := loadChain(ctx, s.blockDB, contractID)
+	if err != nil {
+		return false, err
+	}
+
+	if len(chain) == 0 {
+		return false, nil
+	}
+	last := chain[len(chain)-1]
+
+	if head == nil {
+		// Without a head record the links alone prove nothing, so the whole
+		// chain must verify before it is adopted.
+		if err := blocks.VerifyChain(chain, ed25519.PublicKey(s.pubKey), s.journal); err != nil {
+			return s.settle(ctx, contractID, head, final, func() error {
+				return s.tampered(ctx, contractID, err.Error(), head, last)
+			})
+		}
+		return s.settle(ctx, contractID, head, final, func() error {
+			slog.Warn("Contract has no signed head, recording current head", "contract_id", contractID, "block_index", last.BlockIndex, "block_hash", last.Hash)
+			return s.heads.SaveHead(ctx, blocks.NewHead(last, s.privKey, time.Now().UTC().UnixMilli()))
+		})
+	}
+
+	reason := ""
+	if err := blocks.VerifyLinks(chain); err != nil {
+		reason = err.Error()
+	} else if !blocks.VerifyHead(head, ed25519.PublicKey(s.pubKey)) {
+		reason = "signed head record has an invalid signature"
+	} else if head.BlockIndex != last.BlockIndex || head.BlockHash != last.Hash {
+		reason = fmt.Sprintf("stored chain head #%d %s does not match signed head #%d %s", last.BlockIndex, last.Hash, head.BlockIndex, head.BlockHash)
+	}
+	if reason == "" {
+		return false, nil
+	}
+
+	return s.settle(ctx, contractID, head, final, func() error {
+		return s.tampered(ctx, contractID, reason, head, last)
+	})
+}
+
+// settle runs act under the contract lock if the head record still is the
+// one the chain was checked against, and otherwise reports moved. With final
+// set it acts regardless, since the lock only holds off executions on this
+// replica and another replica may keep moving the head.
+func (s *watchdogService) settle(ctx context.Context, contractID string, head *schema.ContractHead, final bool, act func() error) (moved bool, err error) {
+	s.locker.Lock(contractID)
+	defer s.locker.Unlock(contractID)
+
+	current, err := s.heads.GetHead(ctx, contractID)
+	if err != nil {
+		return false, err
+	}
+
+	if current != nil && current.Frozen {
+		return false, nil
+	}
+
+	if !final && !sameHead(current, head) {
+		return true, nil
+	}
+
+	return false, act()
+}
+
+func sameHead(a, b *schema.ContractHead) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.BlockIndex == b.BlockIndex && a.BlockHash == b.BlockHash
+}
+
+// journal decrypts a block's journal for VerifyChain.
+func (s *watchdogService) journal(b *schema.Block) ([]byte, error) {
+	if blocks.IsGenesis(b) {
+		return nil, nil
+	}
+	return keys.DecryptJournal(b.Journal, s.privKey)
+}
+
+// tampered freezes the contract and raises the alert through the log, the
+// tamper metric and, via the outbox, webhooks.
+func (s *watchdogService) tampered(ctx context.Context, contractID string, reason string, head *schema.ContractHead, last *schema.Block) error {
+	now := time.Now().UTC().UnixMilli()
+
+	slog.Error("Contract chain tampering detected, freezing contract", "contract_id", contractID, "reason", reason)
+	metrics.TamperDetected(contractID)
+
+	if err := s.heads.Freeze(ctx, contractID, reason, now); err != nil {
+		return err
+	}
+
+	data := events.TamperData{
+		Reason:      reason,
+		StoredIndex: last.BlockIndex,
+		StoredHash:  last.Hash,
+		DetectedAt:  now,
+	}
+	if head != nil {
+		data.SignedIndex = head.BlockIndex
+		data.SignedHash = head.BlockHash
+	}
+
+	event, err := events.New(events.ContractTampered, contractID, "", data)
+	if err != nil {
+		return err
+	}
+
+	return s.outbox.Enqueue(ctx, event)
+}
+
+func (s *watchdogService) CheckAll(ctx context.Context) error {
+	contractIDs, err := s.heads.ListChainContracts(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, contractID := range contractIDs {
+		if err := s.Check(ctx, contractID); err != nil {
+			slog.Error("Failed to check contract chain", "contract_id", contractID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Run checks every contract chain every interval until ctx is done.
+func (s *watchdogService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.CheckAll(ctx); err != nil {
+				slog.Error("Failed to run chain watchdog", "error", err)
+			}
+		}
+	}
+}
+
+// loadChain reads a contract's whole chain, ordered by block index.
+func loadChain(ctx context.Context, blockDB repository.BlockRepository, contractID string) ([]*schema.Block, error) {
+	var chain []*schema.Block
+	var afterIndex int64
+	for {
+		page, err := blockDB.ListContractBlocks(ctx, contractID, afterIndex, chainPageSize)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, page...)
+
+		if len(page) < chainPageSize {
+			return chain, nil
+		}
+		afterIndex = page[len(page)-1].BlockIndex
+	}
+}
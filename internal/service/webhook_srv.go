@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/peiblow/eeapi/internal/apperr"
+	"github.com/peiblow/eeapi/internal/auth"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+	"github.com/peiblow/eeapi/internal/webhook"
+)
+
+type WebhookService interface {
+	Register(ctx context.Context, sub *schema.WebhookSubscription) (*schema.WebhookSubscription, error)
+	List(ctx context.Context) ([]*schema.WebhookSubscription, error)
+	Delete(ctx context.Context, id string) error
+	Test(ctx context.Context, id string) (int, error)
+}
+
+type webhookService struct {
+	db         repository.WebhookRepository
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookService(db *postgres.DB, dispatcher *webhook.Dispatcher) WebhookService {
+	return &webhookService{
+		db:         repository.NewPsqlWebhookRepository(db),
+		dispatcher: dispatcher,
+	}
+}
+
+func (s *webhookService) Register(ctx context.Context, sub *schema.WebhookSubscription) (*schema.WebhookSubscription, error) {
+	target, err := url.Parse(sub.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, apperr.New(apperr.CodeValidation, "url must be an absolute http(s) URL")
+	}
+
+	if err := s.dispatcher.CheckTarget(ctx, target.Hostname()); err != nil {
+		return nil, apperr.Wrap(apperr.CodeValidation, "url must point at a public address", err)
+	}
+
+	if sub.Function != "" && sub.ContractID == "" {
+		return nil, apperr.New(apperr.CodeValidation, "function subscriptions require a contract_id")
+	}
+
+	for _, t := range sub.EventTypes {
+		switch events.Type(t) {
+		case events.BlockCommitted, events.ContractDeployed, events.ContractTampered:
+		default:
+			return nil, apperr.New(apperr.CodeValidation, "unknown event type: "+t)
+		}
+	}
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	sub.ID = uuid.New().String()
+	sub.Identity = auth.Identity(ctx)
+	sub.CreatedAt = time.Now().UTC().UnixMilli()
+
+	if err := s.db.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (s *webhookService) List(ctx context.Context) ([]*schema.WebhookSubscription, error) {
+	subs, err := s.db.ListSubscriptions(ctx, auth.Identity(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+
+	return subs, nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return apperr.New(apperr.CodeNotFound, "webhook not found")
+	}
+
+	deleted, err := s.db.DeleteSubscription(ctx, auth.Identity(ctx), id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return apperr.New(apperr.CodeNotFound, "webhook not found")
+	}
+
+	return nil
+}
+
+// Test sends a single webhook.test event to the subscription, without
+// retries, and returns the receiver's status code.
+func (s *webhookService) Test(ctx context.Context, id string) (int, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return 0, apperr.New(apperr.CodeNotFound, "webhook not found")
+	}
+
+	sub, err := s.db.GetSubscription(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, apperr.Wrap(apperr.CodeNotFound, "webhook not found", err)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Subscriptions are only visible to the identity that created them.
+	if sub.Identity != auth.Identity(ctx) {
+		return 0, apperr.New(apperr.CodeNotFound, "webhook not found")
+	}
+
+	target, err := url.Parse(sub.URL)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.dispatcher.CheckTarget(ctx, target.Hostname()); err != nil {
+		return 0, apperr.Wrap(apperr.CodeValidation, "url must point at a public address", err)
+	}
+
+	event, err := events.New(events.WebhookTest, sub.ContractID, sub.Function, map[string]string{"subscription_id": sub.ID})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.dispatcher.Deliver(ctx, sub, event)
+}
@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/peiblow/eeapi/internal/events"
+)
+
+const subscriberBuffer = 64
+
+// Broadcaster fans committed blocks out to the in-process subscribers of
+// each contract. A subscriber that falls behind is dropped rather than
+// blocking the publisher; it can resume from the last block it saw.
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+type Subscription struct {
+	C <-chan events.Event
+
+	ch         chan events.Event
+	contractID string
+	b          *Broadcaster
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subs: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broadcaster) Subscribe(contractID string) *Subscription {
+	ch := make(chan events.Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, contractID: contractID, b: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return sub
+	}
+
+	if b.subs[contractID] == nil {
+		b.subs[contractID] = make(map[*Subscription]struct{})
+	}
+	b.subs[contractID][sub] = struct{}{}
+
+	return sub
+}
+
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	s.b.remove(s)
+}
+
+func (b *Broadcaster) Publish(ctx context.Context, event events.Event) error {
+	if event.Type != events.BlockCommitted {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[event.ContractID] {
+		select {
+		case sub.ch <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+
+	return nil
+}
+
+// Close ends every subscription, and any made later, so streams finish when
+// the server shuts down.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+func (b *Broadcaster) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.closed
+}
+
+// remove must be called with b.mu held.
+func (b *Broadcaster) remove(sub *Subscription) {
+	subs := b.subs[sub.contractID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.ch)
+
+	if len(subs) == 0 {
+		delete(b.subs, sub.contractID)
+	}
+}
+
+// BlockIndex extracts the block index carried by a block.committed event.
+func BlockIndex(event events.Event) int64 {
+	var data events.BlockData
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return 0
+	}
+
+	return data.BlockIndex
+}
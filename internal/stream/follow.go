@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/events"
+)
+
+var (
+	ErrSubscriberLagged = errors.New("subscriber fell behind and was dropped")
+	ErrClosed           = errors.New("broadcaster closed")
+)
+
+// ReplayFunc returns the block events of a contract committed after the
+// given index, in index order. An empty result ends the replay.
+type ReplayFunc func(ctx context.Context, contractID string, afterIndex int64) ([]events.Event, error)
+
+// Follow sends every block of the contract after lastIndex to send: first
+// the stored ones via replay, then new ones as they are committed. It
+// subscribes before replaying so no block falls in between, and replays
+// again whenever a live event skips ahead of the last block sent, since
+// events from other replicas may arrive out of order or not at all. heartbeat
+// is called whenever the stream has been idle for the given interval.
+func Follow(ctx context.Context, b *Broadcaster, contractID string, lastIndex int64, replay ReplayFunc, send func(events.Event) error, heartbeat func() error, interval time.Duration) error {
+	sub := b.Subscribe(contractID)
+	defer sub.Close()
+
+	catchUp := func() error {
+		for {
+			page, err := replay(ctx, contractID, lastIndex)
+			if err != nil {
+				return err
+			}
+			if len(page) == 0 {
+				return nil
+			}
+
+			for _, event := range page {
+				if err := send(event); err != nil {
+					return err
+				}
+				lastIndex = BlockIndex(event)
+			}
+		}
+	}
+
+	if err := catchUp(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				if b.Closed() {
+					return ErrClosed
+				}
+				return ErrSubscriberLagged
+			}
+
+			index := BlockIndex(event)
+			if index > lastIndex+1 {
+				if err := catchUp(); err != nil {
+					return err
+				}
+			}
+			if index <= lastIndex {
+				continue
+			}
+
+			if err := send(event); err != nil {
+				return err
+			}
+			lastIndex = index
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/peiblow/eeapi/internal/database/postgres"
+	"github.com/peiblow/eeapi/internal/events"
+)
+
+const NotifyChannel = "eeapi_blocks"
+
+type notification struct {
+	Origin string       `json:"origin"`
+	Event  events.Event `json:"event"`
+}
+
+// PgNotifier forwards committed blocks to the other eeapi replicas through
+// Postgres NOTIFY. Origin identifies this process so it can ignore its own
+// notifications.
+type PgNotifier struct {
+	db     *postgres.DB
+	origin string
+}
+
+func NewPgNotifier(db *postgres.DB, origin string) *PgNotifier {
+	return &PgNotifier{db: db, origin: origin}
+}
+
+func (n *PgNotifier) Publish(ctx context.Context, event events.Event) error {
+	if event.Type != events.BlockCommitted {
+		return nil
+	}
+
+	payload, err := json.Marshal(notification{Origin: n.origin, Event: event})
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, NotifyChannel, string(payload))
+	return err
+}
+
+// Listen feeds blocks committed by other replicas into the broadcaster until
+// ctx is done.
+func Listen(ctx context.Context, dsn string, origin string, b *Broadcaster) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Block notification listener event", "event", ev, "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(NotifyChannel); err != nil {
+		return err
+	}
+	slog.Info("Listening for block notifications", "channel", NotifyChannel)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established;
+			// anything sent meanwhile is lost and clients resume by index.
+			if n == nil {
+				continue
+			}
+
+			var msg notification
+			if err := json.Unmarshal([]byte(n.Extra), &msg); err != nil {
+				slog.Error("Invalid block notification", "error", err)
+				continue
+			}
+
+			if msg.Origin == origin {
+				continue
+			}
+
+			b.Publish(ctx, msg.Event)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package swp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
 	"sync"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/logging"
+	"github.com/peiblow/eeapi/internal/metrics"
+	"github.com/peiblow/eeapi/internal/retry"
+	"github.com/peiblow/eeapi/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MessageType string
@@ -19,6 +29,10 @@ type WireMesage struct {
 	Type MessageType `json:"type"`
 	ID   string      `json:"id"`
 	Data interface{} `json:"data"`
+
+	// Trace carries the W3C trace context (traceparent, tracestate) so the
+	// VM can continue the caller's trace.
+	Trace map[string]string `json:"trace,omitempty"`
 }
 
 type DeployPayload struct {
@@ -27,6 +41,10 @@ type DeployPayload struct {
 	Version      string `json:"version"`
 	Owner        string `json:"owner"`
 	Source       []byte `json:"source"`
+
+	// MaxPrice is the contract's default execution price limit. eeapi keeps
+	// it with the contract; it is not sent to the VM.
+	MaxPrice int64 `json:"max_price,omitempty"`
 }
 
 type ArtifactMetadata struct {
@@ -59,6 +77,8 @@ type ExecPayload struct {
 	ContractArtifact ArtifactMetadata `json:"contract_artifact"`
 	Function         string           `json:"function"`
 	Args             map[string]any   `json:"args"`
+	// MaxPrice bounds what the execution may cost; zero means no limit.
+	MaxPrice int64 `json:"max_price,omitempty"`
 }
 
 type ExecResponse struct {
@@ -81,79 +101,307 @@ type WireResponse struct {
 	Error   string          `json:"error,omitempty"`
 }
 
+const (
+	dialTimeout = 5 * time.Second
+
+	// PingInterval is how often a connected client pings the SVM. A ping
+	// that waits behind executions for longer than pingTimeout fails, since
+	// a new execution would wait as long.
+	PingInterval = 15 * time.Second
+	pingTimeout  = 10 * time.Second
+)
+
 type SwpClient struct {
 	addr string
 	conn net.Conn
 	mu   sync.Mutex
+
+	breaker *breaker
+	// lost wakes KeepConnected when a call drops the connection.
+	lost chan struct{}
+
+	statusMu sync.Mutex
+	status   ClientStatus
+}
+
+// ClientStatus describes the SVM connection for diagnostics.
+type ClientStatus struct {
+	Addr        string `json:"addr"`
+	Connected   bool   `json:"connected"`
+	Reconnects  int64  `json:"reconnects"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt int64  `json:"last_error_at,omitempty"`
+	LastPingMs  int64  `json:"last_ping_ms,omitempty"`
+	LastPingAt  int64  `json:"last_ping_at,omitempty"`
+	PingError   string `json:"ping_error,omitempty"`
+	Circuit     string `json:"circuit"`
 }
 
 func NewSwpClient(addr string) *SwpClient {
 	return &SwpClient{
-		addr: addr,
+		addr:    addr,
+		breaker: newBreaker(breakerThreshold, breakerCooldown),
+		lost:    make(chan struct{}, 1),
 	}
 }
 
-func (sc *SwpClient) Connect() error {
-	conn, err := net.Dial("tcp", sc.addr)
+func (sc *SwpClient) Connect(ctx context.Context) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.conn != nil {
+		return nil
+	}
+
+	conn, err := dial(ctx, sc.addr)
 	if err != nil {
-		panic(err)
+		sc.setConnected(err)
+		return err
 	}
 	sc.conn = conn
+	sc.setConnected(nil)
+	sc.breaker.reset()
 	return nil
 }
 
-func (sc *SwpClient) Send(msg WireMesage, resp any) error {
+// KeepConnected dials the SVM until ctx is done, backing off with jitter while
+// it is unreachable and redialling as soon as a call drops the connection.
+// While connected it pings the SVM every PingInterval; the result is part of
+// Status.
+func (sc *SwpClient) KeepConnected(ctx context.Context, backoff retry.Backoff) {
+	logger := logging.FromContext(ctx).With("addr", sc.addr)
+
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+
+	for {
+		err := retry.Do(ctx, backoff, sc.Connect, func(attempt int, err error, delay time.Duration) {
+			metrics.SWPReconnect(err)
+			logger.Warn("SVM unreachable, retrying", "attempt", attempt, "retry_in", delay, "error", err)
+		})
+		if err != nil {
+			return
+		}
+		logger.Info("Connected to SVM")
+
+	connected:
+		for {
+			sc.ping(ctx, logger)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-sc.lost:
+				break connected
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+func (sc *SwpClient) ping(ctx context.Context, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if _, err := sc.Ping(ctx); err != nil && ctx.Err() != context.Canceled {
+		logger.Warn("SVM ping failed", "error", err)
+	}
+}
+
+func dial(ctx context.Context, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: dialTimeout}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (sc *SwpClient) Status() ClientStatus {
+	sc.statusMu.Lock()
+	defer sc.statusMu.Unlock()
+
+	status := sc.status
+	status.Addr = sc.addr
+	status.Circuit = sc.breaker.state()
+	return status
+}
+
+func (sc *SwpClient) setConnected(err error) {
+	sc.statusMu.Lock()
+	defer sc.statusMu.Unlock()
+
+	sc.status.Connected = err == nil
+	if err != nil {
+		sc.status.LastError = err.Error()
+		sc.status.LastErrorAt = time.Now().UTC().UnixMilli()
+	}
+}
+
+// Ping makes a PING round trip to the SVM, records the result in the
+// client's status and returns its latency.
+func (sc *SwpClient) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+
+	var resp WireResponse
+	err := sc.Send(ctx, WireMesage{
+		Type: PING,
+		ID:   fmt.Sprintf("ping-%d", start.UnixNano()),
+		Data: PingPayload{Timestamp: start.UnixMilli()},
+	}, &resp)
+	latency := time.Since(start)
+	if err == nil && !resp.Success {
+		err = fmt.Errorf("ping rejected: %s", resp.Error)
+	}
+
+	sc.statusMu.Lock()
+	sc.status.LastPingAt = time.Now().UTC().UnixMilli()
+	sc.status.LastPingMs = latency.Milliseconds()
+	sc.status.PingError = ""
+	if err != nil {
+		sc.status.PingError = err.Error()
+	}
+	sc.statusMu.Unlock()
+
+	return latency, err
+}
+
+func (sc *SwpClient) Send(ctx context.Context, msg WireMesage, resp any) error {
+	ctx, span := tracing.Start(ctx, "swp."+string(msg.Type),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("swp.message_id", msg.ID),
+			attribute.String("server.address", sc.addr),
+		),
+	)
+	msg.Trace = tracing.Inject(ctx)
+
+	// While the circuit is open the VM is known to be down, so callers get
+	// an answer now instead of waiting on a dial.
+	if !sc.breaker.allow() {
+		metrics.ObserveSWP(string(msg.Type), ErrCircuitOpen, 0)
+		tracing.End(span, ErrCircuitOpen)
+		return ErrCircuitOpen
+	}
+
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	return sc.sendWithRetry(msg, resp, true)
+	// The caller may have given up while waiting for the connection. That
+	// says nothing about the VM, so the connection and breaker are left as
+	// they are.
+	if err := ctx.Err(); err != nil {
+		metrics.ObserveSWP(string(msg.Type), err, 0)
+		tracing.End(span, err)
+		return err
+	}
+
+	// Bound the socket I/O by the caller's deadline. A connection that
+	// failed mid-message is dropped so the next call starts clean.
+	if sc.conn != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			sc.conn.SetDeadline(deadline)
+			defer func() {
+				if sc.conn != nil {
+					sc.conn.SetDeadline(time.Time{})
+				}
+			}()
+		}
+	}
+
+	start := time.Now()
+	err := sc.sendWithRetry(ctx, msg, resp, true)
+	// A connection that failed mid-message may still deliver the response
+	// later, so it is dropped even when the caller's deadline caused it.
+	if err != nil && sc.conn != nil {
+		sc.conn.Close()
+		sc.conn = nil
+		sc.setConnected(err)
+	}
+	if err != nil {
+		select {
+		case sc.lost <- struct{}{}:
+		default:
+		}
+	}
+	// Only failures of the VM count towards opening the circuit, not the
+	// caller running out of time.
+	if ctx.Err() == nil {
+		sc.breaker.record(err)
+	}
+	metrics.ObserveSWP(string(msg.Type), err, time.Since(start))
+	tracing.End(span, err)
+
+	return err
 }
 
-func (sc *SwpClient) sendWithRetry(msg WireMesage, resp any, canRetry bool) error {
-	fmt.Printf("[SWP] Sending message type=%s id=%s (retry=%v)\n", msg.Type, msg.ID, !canRetry)
+func (sc *SwpClient) sendWithRetry(ctx context.Context, msg WireMesage, resp any, canRetry bool) error {
+	logger := logging.FromContext(ctx).With("swp_type", msg.Type, "swp_id", msg.ID)
+	logger.Debug("Sending SWP message", "retry", !canRetry)
+
+	if sc.conn == nil {
+		if err := sc.reconnect(ctx); err != nil {
+			return err
+		}
+	}
 
 	if err := Encode(sc.conn, msg); err != nil {
-		fmt.Printf("[SWP] Encode error: %v\n", err)
-		if canRetry {
-			if err := sc.reconnect(); err != nil {
+		logger.Warn("SWP encode failed", "error", err)
+		if canRetry && ctx.Err() == nil {
+			if err := sc.reconnect(ctx); err != nil {
 				return err
 			}
-			return sc.sendWithRetry(msg, resp, false)
+			return sc.sendWithRetry(ctx, msg, resp, false)
 		}
 		return err
 	}
-	fmt.Println("[SWP] Encode success, waiting for response...")
+	logger.Debug("SWP message sent, waiting for response")
 
 	if err := Decode(sc.conn, resp); err != nil {
-		fmt.Printf("[SWP] Decode error: %v\n", err)
-		if canRetry {
-			if err := sc.reconnect(); err != nil {
+		logger.Warn("SWP decode failed", "error", err)
+		if canRetry && ctx.Err() == nil {
+			if err := sc.reconnect(ctx); err != nil {
 				return err
 			}
-			return sc.sendWithRetry(msg, resp, false)
+			return sc.sendWithRetry(ctx, msg, resp, false)
 		}
 		return err
 	}
-	fmt.Println("[SWP] Decode success")
+	logger.Debug("SWP response received")
 
 	return nil
 }
 
-func (sc *SwpClient) reconnect() error {
-	fmt.Println("[SWP] Reconnecting...")
+func (sc *SwpClient) reconnect(ctx context.Context) error {
+	logger := logging.FromContext(ctx).With("addr", sc.addr)
+	logger.Info("Reconnecting to SVM")
 	if sc.conn != nil {
 		sc.conn.Close()
 	}
-	conn, err := net.Dial("tcp", sc.addr)
+	conn, err := dial(ctx, sc.addr)
+	metrics.SWPReconnect(err)
+
+	sc.statusMu.Lock()
+	sc.status.Reconnects++
+	sc.statusMu.Unlock()
+
 	if err != nil {
-		fmt.Printf("[SWP] Reconnect failed: %v\n", err)
+		sc.conn = nil
+		sc.setConnected(err)
+		logger.Error("SVM reconnect failed", "error", err)
 		return err
 	}
 	sc.conn = conn
-	fmt.Println("[SWP] Reconnected!")
+	sc.setConnected(nil)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	logger.Info("Reconnected to SVM")
 	return nil
 }
 
 func (sc *SwpClient) Close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.conn == nil {
+		return nil
+	}
 	return sc.conn.Close()
 }
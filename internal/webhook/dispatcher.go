@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/peiblow/eeapi/internal/events"
+	"github.com/peiblow/eeapi/internal/repository"
+	"github.com/peiblow/eeapi/internal/schema"
+)
+
+const (
+	HeaderSignature = "X-EEAPI-Signature"
+	HeaderEvent     = "X-EEAPI-Event"
+	HeaderDelivery  = "X-EEAPI-Delivery"
+)
+
+type Config struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	// Interval is how often due deliveries are polled for, and BatchSize
+	// how many are sent at once.
+	Interval  time.Duration
+	BatchSize int
+	// AllowPrivateTargets lets subscriptions point at loopback and private
+	// addresses, for local development.
+	AllowPrivateTargets bool
+}
+
+// Dispatcher delivers events to matching webhook subscriptions. Publishing
+// queues a delivery per subscription in the database, so an event the outbox
+// handed over survives a restart; Run sends the queued deliveries, retrying
+// with exponential backoff until the attempts are exhausted and the event is
+// written to the dead-letter table.
+type Dispatcher struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	cfg    Config
+}
+
+func NewDispatcher(repo repository.WebhookRepository, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 50
+	}
+
+	return &Dispatcher{
+		repo:   repo,
+		client: newClient(cfg),
+		cfg:    cfg,
+	}
+}
+
+// newClient returns the delivery client. Unless private targets are allowed
+// it checks every address it connects to, including redirect targets, and
+// connects directly, since behind a proxy it could only check the proxy.
+func newClient(cfg Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateTargets {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}
+}
+
+// Publish queues the event for every matching subscription. It returns only
+// once every delivery is stored, so the outbox keeps the event until then.
+func (d *Dispatcher) Publish(ctx context.Context, event events.Event) error {
+	subs, err := d.repo.ListMatchingSubscriptions(ctx, event.ContractID, event.Function)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	for _, sub := range subs {
+		if !sub.Matches(string(event.Type)) {
+			continue
+		}
+
+		err := d.repo.EnqueueDelivery(ctx, &schema.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      string(event.Type),
+			Payload:        payload,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver makes a single delivery attempt and reports the response status.
+func (d *Dispatcher) Deliver(ctx context.Context, sub *schema.WebhookSubscription, event events.Event) (int, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.send(ctx, sub, string(event.Type), event.ID, payload)
+}
+
+// Run sends queued deliveries until ctx is done. Deliveries in flight when
+// ctx is done are finished, bounded by the client timeout.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		claimed, err := d.deliverBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Webhook delivery batch failed", "error", err)
+		}
+
+		// A full batch means there is probably more waiting.
+		if err == nil && claimed == d.cfg.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverBatch(ctx context.Context) (int, error) {
+	deliveries, err := d.repo.ClaimDeliveries(ctx, d.cfg.BatchSize, d.cfg.Timeout+time.Minute)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *schema.WebhookDelivery) {
+	logger := slog.With("subscription_id", delivery.SubscriptionID, "event_id", delivery.EventID, "attempt", delivery.Attempts)
+
+	sub, err := d.repo.GetSubscription(ctx, delivery.SubscriptionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Info("Dropping webhook delivery for deleted subscription")
+		if err := d.repo.DeleteDelivery(ctx, delivery.ID); err != nil {
+			logger.Error("Failed to drop webhook delivery", "error", err)
+		}
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load webhook subscription", "error", err)
+		return
+	}
+
+	status, err := d.send(ctx, sub, delivery.EventType, delivery.EventID, delivery.Payload)
+	if err == nil {
+		if err := d.repo.DeleteDelivery(ctx, delivery.ID); err != nil {
+			logger.Error("Failed to mark webhook delivered", "error", err)
+		}
+		return
+	}
+	logger.Warn("Webhook delivery failed", "status", status, "error", err)
+
+	if delivery.Attempts < d.cfg.MaxAttempts {
+		if err := d.repo.RetryDelivery(ctx, delivery.ID, status, err.Error(), d.backoff(delivery.Attempts)); err != nil {
+			logger.Error("Failed to schedule webhook retry", "error", err)
+		}
+		return
+	}
+
+	letter := &schema.WebhookDeadLetter{
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Attempts:       delivery.Attempts,
+		LastStatus:     status,
+		LastError:      err.Error(),
+		CreatedAt:      time.Now().UTC().UnixMilli(),
+	}
+	if err := d.repo.DeadLetterDelivery(ctx, delivery.ID, letter); err != nil {
+		logger.Error("Failed to save webhook dead letter", "error", err)
+		return
+	}
+	logger.Error("Webhook delivery moved to dead letters")
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.cfg.BaseBackoff
+	for i := 1; i < attempts && backoff < d.cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, d.cfg.MaxBackoff)
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *schema.WebhookSubscription, eventType string, eventID string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, eventID)
+	req.Header.Set(HeaderSignature, "t="+timestamp+",v1="+Sign(sub.Secret, timestamp, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign computes the HMAC-SHA256 of "<timestamp>.<payload>" with the
+// subscription secret. Receivers recompute it to authenticate a delivery.
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
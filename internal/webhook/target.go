@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which net/netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// public reports whether deliveries may be sent to addr. Loopback, private,
+// link-local and other non-routable addresses are refused so a subscription
+// cannot make the node call services on its own network.
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// CheckTarget resolves host and fails with ErrPrivateTarget if any of its
+// addresses is not public. Deliveries check the address they connect to
+// again, since the name may resolve differently by then.
+func (d *Dispatcher) CheckTarget(ctx context.Context, host string) error {
+	if d.cfg.AllowPrivateTargets {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+
+	for _, addr := range addrs {
+		if !public(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateTarget, host, addr)
+		}
+	}
+
+	return nil
+}
+
+// dialPublic is a net.Dialer Control function that refuses connections to
+// addresses that are not public.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !public(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, addrPort.Addr())
+	}
+
+	return nil
+}
@@ -0,0 +1,2 @@
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS args_hash TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS blocks_hash_idx ON blocks (hash);
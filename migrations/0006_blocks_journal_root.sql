@@ -0,0 +1,3 @@
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS journal_root TEXT NOT NULL DEFAULT '';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS artifact_hash TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS blocks_contract_index_idx ON blocks (contract_id, block_index);
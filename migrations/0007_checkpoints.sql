@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS checkpoints (
+    seq BIGINT PRIMARY KEY,
+    root TEXT NOT NULL,
+    previous_hash TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    signature BYTEA NOT NULL,
+    head_count INT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS checkpoint_heads (
+    checkpoint_seq BIGINT NOT NULL REFERENCES checkpoints (seq),
+    position INT NOT NULL,
+    contract_id TEXT NOT NULL,
+    block_index BIGINT NOT NULL,
+    block_hash TEXT NOT NULL,
+    PRIMARY KEY (checkpoint_seq, position)
+);
+
+CREATE INDEX IF NOT EXISTS checkpoint_heads_contract_idx ON checkpoint_heads (contract_id, block_index);
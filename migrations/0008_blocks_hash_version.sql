@@ -0,0 +1 @@
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS hash_version INT NOT NULL DEFAULT 1;
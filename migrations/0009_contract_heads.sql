@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS contract_heads (
+    contract_id TEXT PRIMARY KEY,
+    block_index BIGINT NOT NULL,
+    block_hash TEXT NOT NULL,
+    signature BYTEA NOT NULL,
+    updated_at BIGINT NOT NULL,
+    frozen BOOLEAN NOT NULL DEFAULT FALSE,
+    frozen_reason TEXT NOT NULL DEFAULT '',
+    frozen_at BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,14 @@
+-- A NULL limit falls back to the configured default; 0 means unlimited.
+CREATE TABLE IF NOT EXISTS quotas (
+    identity TEXT PRIMARY KEY,
+    daily_executions BIGINT,
+    exec_price_budget BIGINT,
+    exec_price_spent BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS quota_executions (
+    identity TEXT NOT NULL,
+    day DATE NOT NULL,
+    executions BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (identity, day)
+);
@@ -0,0 +1,15 @@
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS exec_price BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS usage_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    contract_id TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    caller TEXT NOT NULL,
+    function_name TEXT NOT NULL,
+    block_index BIGINT NOT NULL,
+    block_hash TEXT NOT NULL,
+    exec_price BIGINT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS usage_ledger_created_at_idx ON usage_ledger (created_at);
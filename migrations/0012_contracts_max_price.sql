@@ -0,0 +1 @@
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS max_price BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- identity was first added by 0010_quotas.sql; it belongs with the jobs
+-- table, and IF NOT EXISTS keeps databases that already have it working.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS identity TEXT NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lease_until BIGINT NOT NULL DEFAULT 0;
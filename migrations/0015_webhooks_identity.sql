@@ -0,0 +1,3 @@
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS identity TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_identity_idx ON webhook_subscriptions (identity);
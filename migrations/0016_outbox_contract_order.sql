@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS outbox_pending_contract_idx ON outbox (contract_id, id) WHERE delivered_at IS NULL;